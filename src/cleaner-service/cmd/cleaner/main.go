@@ -1,38 +1,62 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"cleaner-service/internal/clean"
 	"cleaner-service/internal/queue"
+	sharedqueue "shared/queue"
 )
 
+// Maximum time to wait for in-flight messages after a shutdown signal
+const shutdownTimeout = 45 * time.Second
+
+// Time to wait before restarting a consumer whose queue is missing or whose connection was lost
+const restartDelay = 15 * time.Second
+
 func main() {
-	// List of queues to consume from
-	queues := []string{
-		"taxi_trips_raw",
-		"covid_cases_raw",
-		"covid_vulnerability_index_raw",
-		"building_permits_raw",
-		"census_data_raw",
-		"transportation_trips_raw",
-		"public_health_statistics_raw",
+	// Cancel the root context on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Consume the raw queue of every dataset that has cleaning rules
+	datasets, err := clean.Datasets()
+	if err != nil {
+		log.Fatalf("Failed to load cleaning rules: %v", err)
+	}
+
+	// Check the within_chicago validators against the official city boundary
+	if err := clean.SetupCityBoundary(); err != nil {
+		log.Fatalf("Failed to load the city boundary: %v", err)
+	}
+
+	// Carry the trailing averages of the data quality metrics over from the previous runs.
+	// They only decide alerts, so the cleaner runs without them if the database is unavailable.
+	if err := clean.SetupQualityHistory(); err != nil {
+		log.Printf("Trailing averages of the data quality metrics start empty: %v", err)
 	}
 
-	for _, queueName := range queues {
+	var wg sync.WaitGroup
+	for _, dataset := range datasets {
+		queueName := dataset + "_raw"
+		wg.Add(1)
 		go func(queueName string) {
-			for {
-				err := queue.StartConsumer(queueName, queue.ProcessMessage)
-				if err != nil {
-					log.Printf("Waiting for queue %s to appear: %v", queueName, err)
-					time.Sleep(15 * time.Second)
-				} else {
-					break
-				}
-			}
+			defer wg.Done()
+			sharedqueue.KeepConsuming(ctx, queueName, restartDelay, func(ctx context.Context) error {
+				return queue.StartConsumer(ctx, queueName, queue.ProcessMessage)
+			})
 		}(queueName)
 	}
 
-	// Prevent the main function from exiting immediately
-	select {}
+	// Run until a shutdown signal is received
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutdown signal received, finishing in-flight messages")
+	os.Exit(sharedqueue.WaitForShutdown(&wg, shutdownTimeout))
 }
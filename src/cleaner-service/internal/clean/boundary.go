@@ -0,0 +1,27 @@
+package clean
+
+import (
+	"log"
+	"os"
+
+	"shared/geo"
+)
+
+// SetupCityBoundary loads the boundary of the City of Chicago checked by the within_chicago validators
+// from the GeoJSON file at CITY_BOUNDARY. Without it, the validators only check that points lie within
+// the extent of the city. Call it when the service starts, so an unreadable file stops the service
+// before it consumes any message.
+func SetupCityBoundary() error {
+	path := os.Getenv("CITY_BOUNDARY")
+	if path == "" {
+		log.Printf("CITY_BOUNDARY is not set, within_chicago only checks the extent of the city")
+		return nil
+	}
+	boundary, err := geo.ReadChicagoFile(path)
+	if err != nil {
+		return err
+	}
+	geo.SetChicago(boundary)
+	log.Printf("Checking within_chicago against the city boundary in %s", path)
+	return nil
+}
@@ -0,0 +1,174 @@
+package clean
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     interface{}
+		want      int64
+		wantLossy bool
+		wantErr   bool
+	}{
+		{"string", "42", 42, false, false},
+		{"negative string", "-7", -7, false, false},
+		{"whitespace", " 12 ", 12, false, false},
+		{"thousands separators", "1,234,567", 1234567, false, false},
+		{"whole fraction", "12.0", 12, false, false},
+		{"fraction", "12.5", 12, true, false},
+		{"JSON number", float64(8), 8, false, false},
+		{"JSON number with fraction", 3.75, 3, true, false},
+		{"int", 5, 5, false, false},
+		{"int64", int64(math.MaxInt64), math.MaxInt64, false, false},
+		{"misplaced separator", "12,34", 0, false, true},
+		{"text", "twelve", 0, false, true},
+		{"out of range", 1e19, 0, false, true},
+		{"NaN string", "NaN", 0, false, true},
+		{"bool", true, 0, false, true},
+		{"object", map[string]interface{}{}, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, lossy, err := parseInt(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInt(%#v) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want || lossy != tt.wantLossy {
+				t.Errorf("parseInt(%#v) = %d, lossy %v, want %d, lossy %v", tt.value, got, lossy, tt.want, tt.wantLossy)
+			}
+		})
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     interface{}
+		want      float64
+		wantLossy bool
+		wantErr   bool
+	}{
+		{"string", "41.899602111", 41.899602111, false, false},
+		{"negative string", "-87.633308037", -87.633308037, false, false},
+		{"whitespace", "\t3.5 ", 3.5, false, false},
+		{"thousands separators", "12,345.67", 12345.67, false, false},
+		{"exponent", "1e3", 1000, false, false},
+		{"JSON number", 0.05, 0.05, false, false},
+		{"int", 7, 7, false, false},
+		{"exact int64", int64(1 << 53), 1 << 53, false, false},
+		{"inexact int64", int64(1<<53 + 1), 1 << 53, true, false},
+		{"text", "n/a", 0, false, true},
+		{"NaN", "NaN", 0, false, true},
+		{"infinity", "+Inf", 0, false, true},
+		{"bool", false, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, lossy, err := parseFloat(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFloat(%#v) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want || lossy != tt.wantLossy {
+				t.Errorf("parseFloat(%#v) = %v, lossy %v, want %v, lossy %v", tt.value, got, lossy, tt.want, tt.wantLossy)
+			}
+		})
+	}
+}
+
+func TestParseString(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{"string", "Loop", "Loop", false},
+		{"zip code number", float64(60601), "60601", false},
+		{"fraction", 12.5, "12.5", false},
+		{"int", 8, "8", false},
+		{"int64", int64(-3), "-3", false},
+		{"bool", true, "true", false},
+		{"list", []interface{}{"a"}, "", true},
+		{"object", map[string]interface{}{"zip": "60601"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseString(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseString(%#v) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseString(%#v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    bool
+		wantErr bool
+	}{
+		{true, true, false},
+		{false, false, false},
+		{"true", true, false},
+		{"T", true, false},
+		{" Yes ", true, false},
+		{"y", true, false},
+		{"1", true, false},
+		{"FALSE", false, false},
+		{"f", false, false},
+		{"No", false, false},
+		{"n", false, false},
+		{"0", false, false},
+		{float64(1), true, false},
+		{float64(0), false, false},
+		{float64(2), false, true},
+		{"maybe", false, true},
+		{int64(1), false, true},
+	}
+	for _, tt := range tests {
+		got, err := parseBool(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBool(%#v) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseBool(%#v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"2023-11-05T01:30:00.000", "2023-11-05T06:30:00Z", false}, // Floating, as published by the data portal
+		{"2023-03-12T02:30:00", "2023-03-12T08:30:00Z", false},
+		{" 2023-07-04T12:00:00.000 ", "2023-07-04T17:00:00Z", false},
+		{"2023-11-05T01:30:00Z", "2023-11-05T01:30:00Z", false}, // Zoned timestamps keep their zone
+		{"2023-11-05T01:30:00.000-06:00", "2023-11-05T07:30:00Z", false},
+		{"2023-11-05", "", true},
+		{"11/05/2023 01:30:00 AM", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseTime(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTime(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if want, _ := time.Parse(time.RFC3339, tt.want); !got.Equal(want) {
+			t.Errorf("parseTime(%q) = %s, want %s", tt.value, got.UTC().Format(time.RFC3339), tt.want)
+		}
+	}
+}
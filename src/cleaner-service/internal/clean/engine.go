@@ -0,0 +1,421 @@
+package clean
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"shared/community"
+	"shared/geo"
+)
+
+// Rule names reported for rejections that are not raised by a declared validator
+const (
+	ruleNotObject = "not_object" // The row is not a JSON object
+	ruleMissing   = "missing"    // The key of a required field is missing
+	ruleNull      = "null"       // The key of a required field holds JSON null
+	ruleType      = "type"       // A value cannot be parsed as the field type
+	ruleModel     = "model"      // The cleaned record fails the validation of its model type
+)
+
+// Result is the outcome of cleaning one raw page
+type Result struct {
+	Records       []map[string]interface{} // Cleaned records
+	Rejected      []Rejection              // Rows that were dropped, in page order
+	ParseFailures map[string]int           // Number of rows in which each field could not be parsed as its type
+	Lossy         map[string]int           // Number of rows in which each field lost precision when converted
+	Metrics       []Metric                 // Data quality metrics of the page
+}
+
+// Rejection describes a raw row dropped by the cleaner
+type Rejection struct {
+	Row    int         // Index of the row in the raw page
+	Record interface{} // The row as received
+	Field  string      // Cleaned field that failed, empty for record rules
+	Rule   string      // Rule that rejected the row, e.g. missing, null, type, not_empty or any_present
+	Reason string      // Human readable description of the failure
+}
+
+// ruleError reports which field and rule rejected a row
+type ruleError struct {
+	field string
+	rule  string
+	err   error
+}
+
+func (e *ruleError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ruleError) Unwrap() error {
+	return e.err
+}
+
+// apply cleans the rows of a raw page with the rules of its dataset.
+// Rows that fail a required field or a record validator are dropped and reported.
+func (r *Rules) apply(rows []interface{}, page string) *Result {
+	result := &Result{
+		Records:       make([]map[string]interface{}, 0, len(rows)),
+		ParseFailures: make(map[string]int),
+		Lossy:         make(map[string]int),
+	}
+
+	for row, raw := range rows {
+		var err error
+		var record map[string]interface{}
+		if recMap, ok := raw.(map[string]interface{}); ok {
+			record, err = r.cleanRecord(recMap, page, row, result)
+		} else {
+			err = &ruleError{rule: ruleNotObject, err: fmt.Errorf("row is a %T, not an object", raw)}
+		}
+		if err != nil {
+			rejection := Rejection{Row: row, Record: raw, Reason: err.Error()}
+			var re *ruleError
+			if errors.As(err, &re) {
+				rejection.Field = re.field
+				rejection.Rule = re.rule
+			}
+			log.Printf("Dropping %s row %d: %v", r.Dataset, row, err)
+			result.Rejected = append(result.Rejected, rejection)
+			continue
+		}
+		result.Records = append(result.Records, record)
+	}
+
+	log.Printf("Cleaned %s records: %+v", r.Dataset, result.Records)
+	log.Printf("Number of dropped records: %d", len(result.Rejected))
+	result.Metrics = r.Quality.measure(r.Dataset, len(rows), result)
+	return result
+}
+
+// cleanRecord builds one cleaned record from a row of a page, or reports why the row is rejected.
+// Fields whose value cannot be parsed or loses precision are counted in the result.
+func (r *Rules) cleanRecord(recMap map[string]interface{}, page string, row int, result *Result) (map[string]interface{}, error) {
+	record := make(map[string]interface{}, len(r.Fields))
+
+	for _, f := range r.Fields {
+		value, lossy, err := f.clean(recMap)
+		countParseFailure(err, result.ParseFailures)
+		if lossy {
+			log.Printf("Converted %s of %s row %d to %s %v with loss of precision", f.Source, r.Dataset, row, f.Type, value)
+			result.Lossy[f.Name]++
+		}
+		if err != nil {
+			if f.Required {
+				return nil, err
+			}
+			value, _ = parseValue(f.Type, f.Default) // Checked when the rules were loaded
+		}
+		record[f.Name] = value
+	}
+
+	for _, d := range r.Derived {
+		value, err := d.derive(record)
+		countParseFailure(err, result.ParseFailures)
+		if err != nil {
+			return nil, err
+		}
+		record[d.Name] = value
+	}
+
+	for _, v := range r.Validators {
+		if err := v.check(record, page, row); err != nil {
+			if v.Action == actionNull {
+				log.Printf("Setting %s to null in %s record: %v", strings.Join(v.Fields, ", "), r.Dataset, err)
+				for _, name := range v.Fields {
+					record[name] = nil
+				}
+				continue
+			}
+			return nil, &ruleError{rule: v.Rule, err: err}
+		}
+	}
+
+	if validate, ok := modelValidators[r.Dataset]; ok {
+		if err := validate(record); err != nil {
+			return nil, &ruleError{rule: ruleModel, err: err}
+		}
+	}
+
+	// Remember the keys of the accepted record only, so that a rejected row does not
+	// make a later valid row with the same key a duplicate
+	for _, v := range r.Validators {
+		if v.Rule != "unique" {
+			continue
+		}
+		if key, ok := uniqueKey(record, v.Fields); ok {
+			if seen, dup := v.seen.add(key, page, row, time.Now()); dup {
+				return nil, &ruleError{rule: v.Rule, err: duplicateError(v.Fields, seen, page)}
+			}
+		}
+	}
+	return record, nil
+}
+
+// countParseFailure counts an error that reports a value which cannot be parsed as its type
+func countParseFailure(err error, parseFailures map[string]int) {
+	var re *ruleError
+	if errors.As(err, &re) && re.rule == ruleType {
+		parseFailures[re.field]++
+	}
+}
+
+// clean reads, parses and validates the field from a raw record, and reports whether
+// the conversion to the field type lost precision. A key that is missing from the raw
+// record fails with the missing rule and a key that holds JSON null with the null rule;
+// an empty string is a null value.
+func (f FieldRule) clean(recMap map[string]interface{}) (interface{}, bool, error) {
+	raw, ok := lookup(recMap, f.Source)
+	if !ok {
+		return nil, false, &ruleError{field: f.Name, rule: ruleMissing, err: fmt.Errorf("missing %s", f.Source)}
+	}
+	if raw == nil {
+		return nil, false, &ruleError{field: f.Name, rule: ruleNull, err: fmt.Errorf("%s is null", f.Source)}
+	}
+
+	value, lossy, err := coerce(f.Type, raw)
+	if err != nil {
+		return nil, false, &ruleError{field: f.Name, rule: ruleType, err: fmt.Errorf("invalid %s: %w", f.Source, err)}
+	}
+
+	for _, v := range f.Validators {
+		if err := v.check(value); err != nil {
+			return nil, lossy, &ruleError{field: f.Name, rule: v.Rule, err: fmt.Errorf("invalid %s: %w", f.Source, err)}
+		}
+	}
+	return value, lossy, nil
+}
+
+// derive computes a derived field from a cleaned record
+func (d DerivedRule) derive(record map[string]interface{}) (interface{}, error) {
+	var value interface{}
+	switch d.Derive {
+	case "duration_seconds":
+		start, startOK := record[d.Fields[0]].(time.Time)
+		end, endOK := record[d.Fields[1]].(time.Time)
+		if startOK && endOK {
+			value = int64(end.Sub(start) / time.Second)
+		}
+	case "when_equal":
+		if condition := record[d.Fields[1]]; present(condition) && fmt.Sprint(condition) == d.Equals {
+			var err error
+			if value, err = parseValue(d.Type, record[d.Fields[0]]); err != nil {
+				return nil, &ruleError{field: d.Name, rule: ruleType, err: fmt.Errorf("invalid %s: %w", d.Fields[0], err)}
+			}
+		}
+	}
+
+	for _, v := range d.Validators {
+		if err := v.check(value); err != nil {
+			return nil, &ruleError{field: d.Name, rule: v.Rule, err: fmt.Errorf("invalid %s: %w", d.Name, err)}
+		}
+	}
+	return value, nil
+}
+
+// lookup finds a value in a raw record by a dot-separated path of keys and array indexes
+func lookup(recMap map[string]interface{}, source string) (interface{}, bool) {
+	var current interface{} = recMap
+	for _, key := range strings.Split(source, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			current = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// parseValue converts a raw value to the Go type of a field type.
+// Empty strings are missing values and parse to nil, which is encoded as null.
+func parseValue(typ string, raw interface{}) (interface{}, error) {
+	value, _, err := coerce(typ, raw)
+	return value, err
+}
+
+// coerce converts a raw value to the Go type of a field type, and reports whether the
+// conversion lost precision, such as the fraction of a value read as an int.
+// Null values, empty strings and strings of whitespace parse to nil.
+func coerce(typ string, raw interface{}) (interface{}, bool, error) {
+	if raw == nil {
+		return nil, false, nil
+	}
+	if s, ok := raw.(string); ok && strings.TrimSpace(s) == "" {
+		return nil, false, nil
+	}
+
+	var value interface{}
+	var lossy bool
+	var err error
+	switch typ {
+	case "string":
+		value, err = parseString(raw)
+	case "int":
+		value, lossy, err = parseInt(raw)
+	case "float":
+		value, lossy, err = parseFloat(raw)
+	case "bool":
+		value, err = parseBool(raw)
+	case "timestamp":
+		s, ok := raw.(string)
+		if !ok {
+			return nil, false, fmt.Errorf("invalid type for time conversion: %T", raw)
+		}
+		value, err = parseTime(s)
+	default:
+		return nil, false, fmt.Errorf("unknown type %s", typ)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, lossy, nil
+}
+
+// check applies a value rule to a parsed value; only not_empty rejects a null value
+func (v ValueRule) check(value interface{}) error {
+	if value == nil {
+		if v.Rule == "not_empty" {
+			return fmt.Errorf("empty value")
+		}
+		return nil
+	}
+
+	switch v.Rule {
+	case "not_empty":
+		if t, ok := value.(time.Time); ok && t.IsZero() {
+			return fmt.Errorf("empty value")
+		}
+	case "range":
+		var n float64
+		switch x := value.(type) {
+		case int64:
+			n = float64(x)
+		case float64:
+			n = x
+		default:
+			return nil
+		}
+		if v.Min != nil && n < *v.Min {
+			return fmt.Errorf("%v is below the minimum of %v", n, *v.Min)
+		}
+		if v.Max != nil && n > *v.Max {
+			return fmt.Errorf("%v is above the maximum of %v", n, *v.Max)
+		}
+	case "one_of":
+		s := fmt.Sprintf("%v", value)
+		for _, allowed := range v.Values {
+			if s == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", s, strings.Join(v.Values, ", "))
+	case "pattern":
+		s := fmt.Sprintf("%v", value)
+		if !v.re.MatchString(s) {
+			return fmt.Errorf("%q does not match %s", s, v.Pattern)
+		}
+	}
+	return nil
+}
+
+// check applies a record rule to a cleaned record built from a row of a page
+func (v RecordRule) check(record map[string]interface{}, page string, row int) error {
+	switch v.Rule {
+	case "any_present":
+		for _, name := range v.Fields {
+			if present(record[name]) {
+				return nil
+			}
+		}
+		return fmt.Errorf("all of %s are missing", strings.Join(v.Fields, ", "))
+	case "within_chicago":
+		lat, latOK := record[v.Fields[0]].(float64)
+		lon, lonOK := record[v.Fields[1]].(float64)
+		if !latOK || !lonOK {
+			return nil // Missing coordinates are left to the field rules
+		}
+		if !geo.InChicago(lat, lon) {
+			return fmt.Errorf("%s, %s = %v, %v is outside Chicago", v.Fields[0], v.Fields[1], lat, lon)
+		}
+	case "chronological":
+		start, startOK := record[v.Fields[0]].(time.Time)
+		end, endOK := record[v.Fields[1]].(time.Time)
+		if startOK && endOK && end.Before(start) {
+			return fmt.Errorf("%s %s is before %s %s", v.Fields[1], end.Format(time.RFC3339), v.Fields[0], start.Format(time.RFC3339))
+		}
+	case "community_area":
+		return normalizeCommunityArea(record, v.Fields)
+	case "unique":
+		key, ok := uniqueKey(record, v.Fields)
+		if !ok {
+			return nil // Records without a key are left to the field rules
+		}
+		if seen, dup := v.seen.find(key, page, row, time.Now()); dup {
+			return duplicateError(v.Fields, seen, page)
+		}
+	}
+	return nil
+}
+
+// normalizeCommunityArea replaces the community area number, and name if given, of a record
+// with the canonical community area found by number or else by name
+func normalizeCommunityArea(record map[string]interface{}, fields []string) error {
+	number := record[fields[0]]
+	var name interface{}
+	if len(fields) == 2 {
+		name = record[fields[1]]
+		record[fields[1]] = nil // Set the name even when the area is missing
+	}
+	if !present(number) && !present(name) {
+		record[fields[0]] = nil
+		return nil // Missing community areas are left to the field rules
+	}
+
+	area, ok := community.Lookup(number)
+	if !ok && present(name) {
+		area, ok = community.Lookup(name)
+	}
+	if !ok {
+		if len(fields) == 2 {
+			record[fields[1]] = name
+		}
+		return fmt.Errorf("unknown community area %v (%v)", number, name)
+	}
+
+	// Keep the type of the number field
+	if _, isString := number.(string); isString {
+		record[fields[0]] = strconv.FormatInt(area.Number, 10)
+	} else {
+		record[fields[0]] = area.Number
+	}
+	if len(fields) == 2 {
+		record[fields[1]] = area.Name
+	}
+	return nil
+}
+
+// present reports whether a cleaned value holds data
+func present(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	default:
+		return true
+	}
+}
@@ -0,0 +1,308 @@
+package clean
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shared/geo"
+)
+
+// taxiTrip is a row of the Taxi Trips dataset as published by the data portal
+const taxiTrip = `{
+	"trip_id": "%s",
+	"taxi_id": "c2ba6d2ac1a4b4b9e9c3f3bd7ec1b5bb6d1c8b7e0e0a2b5f8a0c7a6e5d4c3b2a1",
+	"trip_start_timestamp": "2023-06-01T08:15:00.000",
+	"trip_end_timestamp": "2023-06-01T08:30:00.000",
+	"trip_seconds": "900",
+	"trip_miles": "2.1",
+	"pickup_community_area": "8",
+	"dropoff_community_area": "32",
+	"fare": "11.25",
+	"tips": "2.00",
+	"trip_total": "13.25",
+	"payment_type": "Credit Card",
+	"company": "Flash Cab",
+	"pickup_centroid_latitude": "41.899602111",
+	"pickup_centroid_longitude": "-87.633308037",
+	"pickup_centroid_location": {"type": "Point", "coordinates": [-87.6333080367, 41.899602111]},
+	"dropoff_centroid_latitude": "41.880994471",
+	"dropoff_centroid_longitude": "-87.632746489",
+	"dropoff_centroid_location": {"type": "Point", "coordinates": [-87.6327464887, 41.8809944707]}
+}`
+
+// cleanRows cleans rows of a dataset, given as JSON, failing the test on error
+func cleanRows(t *testing.T, dataset string, rows ...string) *Result {
+	t.Helper()
+	var data []interface{}
+	for _, row := range rows {
+		var v interface{}
+		if err := json.Unmarshal([]byte(row), &v); err != nil {
+			t.Fatalf("invalid test row %s: %v", row, err)
+		}
+		data = append(data, v)
+	}
+	page, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := CleanData(page, dataset)
+	if err != nil {
+		t.Fatalf("CleanData() error = %v", err)
+	}
+	return result
+}
+
+// taxiTripRow returns the sample taxi trip with an ID and fields replaced or, for nil values, removed
+func taxiTripRow(t *testing.T, id string, changes map[string]interface{}) string {
+	t.Helper()
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(taxiTrip), &row); err != nil {
+		t.Fatal(err)
+	}
+	row["trip_id"] = id
+	for key, value := range changes {
+		if value == removed {
+			delete(row, key)
+		} else {
+			row[key] = value
+		}
+	}
+	b, err := json.Marshal(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// removed marks a key that taxiTripRow deletes from the sample row
+const removed = "<removed>"
+
+func TestCleanTaxiTrip(t *testing.T) {
+	result := cleanRows(t, "taxi_trips", taxiTripRow(t, "engine-test-accepted", nil))
+	if len(result.Rejected) != 0 || len(result.Records) != 1 {
+		t.Fatalf("CleanData() kept %d and rejected %+v, want the row kept", len(result.Records), result.Rejected)
+	}
+
+	record := result.Records[0]
+	want := map[string]interface{}{
+		"trip_id":                     "engine-test-accepted",
+		"trip_start_timestamp":        time.Date(2023, 6, 1, 13, 15, 0, 0, time.UTC), // 08:15 CDT
+		"trip_end_timestamp":          time.Date(2023, 6, 1, 13, 30, 0, 0, time.UTC),
+		"trip_duration_seconds":       int64(900),
+		"pickup_centroid_latitude":    41.899602111,
+		"pickup_centroid_longitude":   -87.633308037,
+		"pickup_community_area":       int64(8),
+		"pickup_community_area_name":  "Near North Side",
+		"dropoff_community_area":      int64(32),
+		"dropoff_community_area_name": "Loop",
+	}
+	for field, value := range want {
+		got := record[field]
+		if gotTime, ok := got.(time.Time); ok {
+			if !gotTime.Equal(value.(time.Time)) {
+				t.Errorf("%s = %v, want %v", field, gotTime, value)
+			}
+			continue
+		}
+		if got != value {
+			t.Errorf("%s = %#v, want %#v", field, got, value)
+		}
+	}
+	if _, ok := record["fare"]; ok {
+		t.Error("fare is not declared in the rules but was kept")
+	}
+}
+
+func TestCleanTaxiTripRejections(t *testing.T) {
+	tests := []struct {
+		name      string
+		changes   map[string]interface{}
+		wantField string
+		wantRule  string
+	}{
+		{"missing key", map[string]interface{}{"pickup_community_area": removed}, "pickup_community_area", ruleMissing},
+		{"null value", map[string]interface{}{"pickup_community_area": nil}, "pickup_community_area", ruleNull},
+		{"unparsable number", map[string]interface{}{"dropoff_centroid_latitude": "forty-one"}, "dropoff_centroid_latitude", ruleType},
+		{"wrong JSON type", map[string]interface{}{"trip_start_timestamp": float64(1685607300)}, "trip_start_timestamp", ruleType},
+		{"empty ID", map[string]interface{}{"trip_id": ""}, "trip_id", "not_empty"},
+		{"ends before it starts", map[string]interface{}{"trip_end_timestamp": "2023-06-01T08:00:00.000"}, "", "chronological"},
+		{"longer than a day", map[string]interface{}{"trip_end_timestamp": "2023-06-02T08:30:00.000"}, "trip_duration_seconds", "range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := "engine-test-" + tt.name
+			if _, ok := tt.changes["trip_id"]; ok {
+				id = ""
+			}
+			result := cleanRows(t, "taxi_trips", taxiTripRow(t, id, tt.changes))
+			if len(result.Records) != 0 || len(result.Rejected) != 1 {
+				t.Fatalf("CleanData() kept %d records and rejected %d, want the row rejected", len(result.Records), len(result.Rejected))
+			}
+			rejection := result.Rejected[0]
+			if rejection.Field != tt.wantField || rejection.Rule != tt.wantRule {
+				t.Errorf("rejected by %s %s (%s), want %s %s", rejection.Field, rejection.Rule, rejection.Reason, tt.wantField, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestCleanTaxiTripParseFailures(t *testing.T) {
+	result := cleanRows(t, "taxi_trips",
+		taxiTripRow(t, "engine-test-parse-1", map[string]interface{}{"pickup_centroid_longitude": "west"}),
+		taxiTripRow(t, "engine-test-parse-2", map[string]interface{}{"pickup_community_area": "8.5"}),
+		`["not", "an", "object"]`,
+	)
+	if got := result.ParseFailures["pickup_centroid_longitude"]; got != 1 {
+		t.Errorf("ParseFailures[pickup_centroid_longitude] = %d, want 1", got)
+	}
+	if got := result.Lossy["pickup_community_area"]; got != 1 {
+		t.Errorf("Lossy[pickup_community_area] = %d, want 1", got)
+	}
+	if len(result.Rejected) != 2 || result.Rejected[1].Row != 2 || result.Rejected[1].Rule != ruleNotObject {
+		t.Errorf("Rejected = %+v, want row 0 and the list in row 2", result.Rejected)
+	}
+}
+
+func TestCleanTaxiTripOutsideChicago(t *testing.T) {
+	// Zeroed coordinates are cleared, and the trip is kept
+	result := cleanRows(t, "taxi_trips", taxiTripRow(t, "engine-test-null-island", map[string]interface{}{
+		"pickup_centroid_latitude":  "0",
+		"pickup_centroid_longitude": "0",
+	}))
+	if len(result.Records) != 1 {
+		t.Fatalf("CleanData() rejected %+v, want the row kept", result.Rejected)
+	}
+	record := result.Records[0]
+	if record["pickup_centroid_latitude"] != nil || record["pickup_centroid_longitude"] != nil {
+		t.Errorf("pickup centroid = %v, %v, want null", record["pickup_centroid_latitude"], record["pickup_centroid_longitude"])
+	}
+	if record["dropoff_centroid_latitude"] != 41.880994471 {
+		t.Errorf("dropoff_centroid_latitude = %v, want it kept", record["dropoff_centroid_latitude"])
+	}
+}
+
+func TestCleanTaxiTripOutsideCityBoundary(t *testing.T) {
+	// A boundary around the dropoff centroid in the Loop, leaving out the pickup centroid in River North
+	path := filepath.Join(t.TempDir(), "city_boundary.geojson")
+	boundary := `{"type":"Feature","properties":{"name":"CHICAGO"},"geometry":{"type":"Polygon",` +
+		`"coordinates":[[[-87.64,41.875],[-87.62,41.875],[-87.62,41.885],[-87.64,41.885],[-87.64,41.875]]]}}`
+	if err := os.WriteFile(path, []byte(boundary), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CITY_BOUNDARY", path)
+	if err := SetupCityBoundary(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { geo.SetChicago(nil) })
+
+	result := cleanRows(t, "taxi_trips", taxiTripRow(t, "engine-test-city-boundary", nil))
+	if len(result.Records) != 1 {
+		t.Fatalf("CleanData() rejected %+v, want the row kept", result.Rejected)
+	}
+	record := result.Records[0]
+	if record["pickup_centroid_latitude"] != nil || record["dropoff_centroid_latitude"] != 41.880994471 {
+		t.Errorf("centroid latitudes = %v, %v, want null and 41.880994471", record["pickup_centroid_latitude"], record["dropoff_centroid_latitude"])
+	}
+
+	t.Setenv("CITY_BOUNDARY", filepath.Join(t.TempDir(), "missing.geojson"))
+	if err := SetupCityBoundary(); err == nil {
+		t.Error("SetupCityBoundary() of a missing file error = nil, want an error")
+	}
+}
+
+func TestCleanTaxiTripDuplicates(t *testing.T) {
+	row := taxiTripRow(t, "engine-test-duplicate", nil)
+	result := cleanRows(t, "taxi_trips", row, row)
+	if len(result.Records) != 1 || len(result.Rejected) != 1 || result.Rejected[0].Rule != "unique" {
+		t.Fatalf("CleanData() kept %d and rejected %+v, want the second row rejected as a duplicate", len(result.Records), result.Rejected)
+	}
+
+	// A redelivered page is not mistaken for duplicates
+	if again := cleanRows(t, "taxi_trips", row, row); len(again.Records) != 1 {
+		t.Errorf("redelivered page kept %d records, want 1", len(again.Records))
+	}
+}
+
+func TestCleanVulnerabilityIndex(t *testing.T) {
+	result := cleanRows(t, "covid_vulnerability_index",
+		`{"geography_type": "CA", "community_area_or_zip": "1", "community_area_name": "ROGERS PARK", "ccvi_score": "45.4", "ccvi_category": "MEDIUM", "rank_socioeconomic_status": "36", "rank_adults_no_pcp": "39"}`,
+		`{"geography_type": "ZIP", "community_area_or_zip": "60629", "ccvi_score": "63.1", "ccvi_category": "HIGH", "rank_socioeconomic_status": "10"}`,
+		`{"geography_type": "ZIP", "community_area_or_zip": "606", "ccvi_category": "LOW"}`,
+		`{"geography_type": "STATE", "community_area_or_zip": "IL", "ccvi_category": "LOW"}`,
+	)
+	if len(result.Records) != 2 {
+		t.Fatalf("CleanData() kept %d records and rejected %+v, want 2 kept", len(result.Records), result.Rejected)
+	}
+
+	area, zip := result.Records[0], result.Records[1]
+	if area["community_area_number"] != int64(1) || area["community_area_name"] != "Rogers Park" || area["zip_code"] != nil {
+		t.Errorf("community area record = %v, want area 1, Rogers Park and no zip code", area)
+	}
+	if area["ccvi_score"] != 45.4 || area["rank_adults_no_pcp"] != int64(39) || area["rank_age_65_plus"] != nil {
+		t.Errorf("community area scores = %v, want the published score and ranks", area)
+	}
+	if zip["zip_code"] != "60629" || zip["community_area_number"] != nil {
+		t.Errorf("zip code record = %v, want zip code 60629 and no community area", zip)
+	}
+
+	wantRules := []string{"pattern", "one_of"}
+	for i, rejection := range result.Rejected {
+		if rejection.Rule != wantRules[i] {
+			t.Errorf("row %d rejected by %s (%s), want %s", rejection.Row, rejection.Rule, rejection.Reason, wantRules[i])
+		}
+	}
+}
+
+func TestCleanCovidCase(t *testing.T) {
+	result := cleanRows(t, "covid_cases", `{
+		"zip_code": "60601", "week_number": "10", "week_start": "2021-03-07T00:00:00.000", "week_end": "2021-03-13T00:00:00.000",
+		"cases_weekly": "12", "cases_cumulative": "1,043", "case_rate_weekly": "82.5", "case_rate_cumulative": "7170.4",
+		"tests_weekly": "412", "tests_cumulative": "25,231", "test_rate_weekly": "2832.4", "test_rate_cumulative": "173459.2",
+		"percent_tested_positive_weekly": "0.029", "percent_tested_positive_cumulative": "0.041",
+		"deaths_weekly": "0", "deaths_cumulative": "4", "death_rate_weekly": "0", "death_rate_cumulative": "27.5",
+		"population": "14546", "row_id": "60601-2021-10",
+		"zip_code_location": {"type": "Point", "coordinates": [-87.622844, 41.886262]}
+	}`)
+	if len(result.Records) != 1 {
+		t.Fatalf("CleanData() rejected %+v, want the row kept", result.Rejected)
+	}
+	record := result.Records[0]
+	if record["latitude"] != 41.886262 || record["longitude"] != -87.622844 {
+		t.Errorf("location = %v, %v, want the nested coordinates", record["latitude"], record["longitude"])
+	}
+	if record["cases_cumulative"] != int64(1043) || record["population"] != int64(14546) {
+		t.Errorf("counts = %v, %v, want 1043 and 14546", record["cases_cumulative"], record["population"])
+	}
+	if start := record["week_start"].(time.Time); !start.Equal(time.Date(2021, 3, 7, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("week_start = %v, want midnight CST", start)
+	}
+}
+
+func TestCleanDataErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    string
+		dataset string
+	}{
+		{"truncated JSON", `{"data": [{"trip_id": "a"`, "taxi_trips"},
+		{"unknown dataset", `{"data": []}`, "weather"},
+		{"no data key", `{"rows": []}`, "taxi_trips"},
+		{"data not a list", `{"data": {"trip_id": "a"}}`, "taxi_trips"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CleanData([]byte(tt.page), tt.dataset); err == nil {
+				t.Errorf("CleanData(%s) error = nil, want an error", tt.page)
+			}
+		})
+	}
+
+	// The fetcher publishes a null page when a request returns no rows
+	result, err := CleanData([]byte(`{"data": null}`), "taxi_trips")
+	if err != nil || len(result.Records) != 0 {
+		t.Errorf("CleanData(null page) = %v, %v, want no records", result, err)
+	}
+}
@@ -0,0 +1,71 @@
+package clean
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	sharedqueue "shared/queue"
+)
+
+// Datasets the fuzzer picks from, by index
+var fuzzDatasets = []string{
+	"taxi_trips", "transportation_trips", "covid_cases", "covid_vulnerability_index",
+	"building_permits", "census_data", "public_health_statistics",
+}
+
+// FuzzCleanData checks that no raw page, however malformed, makes the cleaner panic:
+// bad input must come back as an error or as rejected rows, never take the panic path
+// that moves the message to the dead-letter queue.
+func FuzzCleanData(f *testing.F) {
+	seeds := []struct {
+		dataset int
+		page    string
+	}{
+		{0, `{"data": [` + fmt.Sprintf(taxiTrip, "fuzz-seed-trip") + `]}`},
+		{0, `{"data": [` + fmt.Sprintf(taxiTrip, "fuzz-seed-trip")[:200]},                             // Truncated
+		{0, `{"data": [{"trip_id": 12, "trip_start_timestamp": true, "pickup_community_area": []}]}`}, // Mistyped
+		{0, `{"data": [{"trip_id": "a", "trip_start_timestamp": "2023-13-45T99:00:00"}]}`},
+		{0, `{"data": null}`},
+		{0, `{"data": "rows"}`},
+		{0, `[]`},
+		{1, `{"data": [{"trip_id": "b", "trip_start_timestamp": "2023-11-05T01:30:00.000", "trip_end_timestamp": "2023-11-05T01:15:00.000", "pickup_centroid_latitude": "41.9", "pickup_centroid_longitude": "-87.6", "pickup_community_area": "1e400"}]}`},
+		{2, `{"data": [{"zip_code": "60601", "week_start": "2021-03-07T00:00:00.000", "cases_weekly": "1,043", "zip_code_location": {"coordinates": [-87.62, 41.88]}}]}`},
+		{2, `{"data": [{"zip_code_location": {"coordinates": "41.88"}}, {"zip_code_location": [1, 2]}]}`},
+		{3, `{"data": [{"geography_type": "CA", "community_area_or_zip": "1", "ccvi_category": "HIGH"}, {"geography_type": "ZIP", "community_area_or_zip": 60629, "ccvi_category": "LOW"}]}`},
+		{3, `{"data": [{"geography_type": "CA", "community_area_or_zip": "78", "ccvi_category": "HIGH", "ccvi_score": "NaN"}]}`},
+		{4, `{"data": [{"id": "1", "permit_": "100", "reported_cost": "-5", "latitude": "0", "longitude": "0", "community_area": "Loop"}]}`},
+		{5, `{"data": [{"community_area_number": "", "community_area_name": "CHICAGO", "per_capita_income_": "1,2,3"}]}`},
+		{6, `{"data": [{"community_area": "77", "community_area_name": "Edgewater", "below_poverty_level": "18.2"}, 7, "row", null]}`},
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed.page), uint8(seed.dataset))
+	}
+
+	f.Fuzz(func(t *testing.T, page []byte, dataset uint8) {
+		source := fuzzDatasets[int(dataset)%len(fuzzDatasets)]
+		var result *Result
+		err := sharedqueue.ProcessSafely(func(body []byte, queueName string) error {
+			var err error
+			result, err = CleanData(body, source)
+			return err
+		}, page, source+"_raw")
+
+		var pe *sharedqueue.PanicError
+		if errors.As(err, &pe) {
+			t.Fatalf("CleanData(%q, %s) panicked: %v\n%s", page, source, pe.Value, pe.Stack)
+		}
+		if err != nil {
+			return
+		}
+
+		// Every row of the page is either kept or rejected
+		var raw struct {
+			Data []json.RawMessage `json:"data"`
+		}
+		if json.Unmarshal(page, &raw) == nil && len(result.Records)+len(result.Rejected) != len(raw.Data) {
+			t.Errorf("CleanData() kept %d and rejected %d of %d rows", len(result.Records), len(result.Rejected), len(raw.Data))
+		}
+	})
+}
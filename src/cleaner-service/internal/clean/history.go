@@ -0,0 +1,77 @@
+package clean
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+	"shared/model"
+	"shared/postgres"
+)
+
+// Postgres error code of a query on a table that does not exist
+const undefinedTable = "42P01"
+
+// Values of each metric of a dataset over its last batches, oldest first. Metrics are numbered per
+// field, metric and category, most recent first, so that only the last $2 values of each are kept.
+const recentMetricsQuery = `SELECT field_name, metric, category, value FROM (
+	SELECT COALESCE(field_name, '') AS field_name, metric, COALESCE(category, '') AS category, value, measured_at, batch_id,
+		ROW_NUMBER() OVER (PARTITION BY field_name, metric, category ORDER BY measured_at DESC, batch_id DESC) AS recent
+	FROM %s WHERE dataset = $1) metrics
+WHERE recent <= $2 ORDER BY measured_at, batch_id;`
+
+// SetupQualityHistory seeds the trailing averages of the data quality metrics with the metrics of the
+// previous batches stored in the data_quality_metrics table, so that they carry over restarts.
+// It connects to Postgres with the POSTGRES_* variables and does nothing when POSTGRES_DB is not set.
+func SetupQualityHistory() error {
+	if !postgres.Configured() {
+		log.Printf("POSTGRES_DB is not set, trailing averages of the data quality metrics start empty")
+		return nil
+	}
+	db, err := sql.Open("postgres", postgres.ConnString())
+	if err != nil {
+		return fmt.Errorf("failed to open the database: %w", err)
+	}
+	defer db.Close()
+
+	sets, err := loadRules()
+	if err != nil {
+		return err
+	}
+	for dataset, rules := range sets {
+		n, err := loadMetricHistory(db, model.QualityMetrics, dataset, &rules.Quality)
+		if err != nil {
+			return fmt.Errorf("failed to load the data quality metrics of %s: %w", dataset, err)
+		}
+		log.Printf("Seeded the trailing averages of %s with %d stored data quality metrics", dataset, n)
+	}
+	return nil
+}
+
+// loadMetricHistory seeds the history of the quality rules of a dataset from the metrics stored in table,
+// and returns the number of metrics read. A missing table, before the first batch is stored, holds none.
+func loadMetricHistory(db *sql.DB, table, dataset string, q *QualityRules) (int, error) {
+	rows, err := db.Query(fmt.Sprintf(recentMetricsQuery, table), dataset, q.TrailingBatches)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == undefinedTable {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var field, metric, category string
+		var value float64
+		if err := rows.Scan(&field, &metric, &category, &value); err != nil {
+			return n, err
+		}
+		q.history.seed(metricKey(field, metric, category), value)
+		n++
+	}
+	return n, rows.Err()
+}
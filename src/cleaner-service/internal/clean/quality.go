@@ -0,0 +1,253 @@
+package clean
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Data quality metrics measured for every batch
+const (
+	MetricNullRate         = "null_rate"          // Cleaned records in which a field is null
+	MetricParseFailureRate = "parse_failure_rate" // Rows in which a field could not be parsed as its type
+	MetricLossyRate        = "lossy_rate"         // Rows in which a field lost precision when converted to its type
+	MetricDuplicateRate    = "duplicate_rate"     // Rows that repeat the key of another row
+	MetricValueShare       = "value_share"        // Cleaned records that hold one category of a categorical field
+)
+
+// Number of previous batches the trailing average covers when a rule file does not set it
+const defaultTrailingBatches = 20
+
+// QualityRules declares the data quality metrics of a dataset and when they raise an alert
+type QualityRules struct {
+	Keys            []string           `json:"keys"`             // Fields that identify a record, for duplicate_rate
+	Categorical     []string           `json:"categorical"`      // Fields whose value distribution is measured
+	Thresholds      map[string]float64 `json:"thresholds"`       // Largest allowed distance of a metric from its trailing average
+	TrailingBatches int                `json:"trailing_batches"` // Number of previous batches averaged
+
+	fields  []string // Every cleaned field, for null_rate
+	history *metricHistory
+}
+
+// Metric is one data quality measurement of a batch
+type Metric struct {
+	Field           string   // Cleaned field, or the comma-separated key fields of duplicate_rate
+	Metric          string   // One of the Metric constants
+	Category        string   // Category of a value_share
+	Value           float64  // Count divided by Total
+	Count           int      // Rows or records the metric counts
+	Total           int      // Rows or records the metric is measured over
+	TrailingAverage *float64 // Average of the metric over the previous batches, nil for the first batch
+	Alert           bool     // Value is further from the trailing average than the threshold of the metric
+}
+
+// check validates the quality rules against the types of the cleaned fields and prepares them for use
+func (q *QualityRules) check(types map[string]string) error {
+	for _, name := range append(append([]string{}, q.Keys...), q.Categorical...) {
+		if types[name] == "" {
+			return fmt.Errorf("quality metrics refer to unknown field %s", name)
+		}
+	}
+	for metric, threshold := range q.Thresholds {
+		switch metric {
+		case MetricNullRate, MetricParseFailureRate, MetricLossyRate, MetricDuplicateRate, MetricValueShare:
+		default:
+			return fmt.Errorf("quality threshold for unknown metric %q", metric)
+		}
+		if threshold < 0 {
+			return fmt.Errorf("quality threshold for %s is negative", metric)
+		}
+	}
+	if q.TrailingBatches < 0 {
+		return fmt.Errorf("quality trailing_batches is negative")
+	}
+	if q.TrailingBatches == 0 {
+		q.TrailingBatches = defaultTrailingBatches
+	}
+
+	q.fields = make([]string, 0, len(types))
+	for name := range types {
+		q.fields = append(q.fields, name)
+	}
+	sort.Strings(q.fields)
+	q.history = newMetricHistory(q.TrailingBatches)
+	return nil
+}
+
+// measure computes the data quality metrics of a cleaned batch of received rows and
+// compares each with its trailing average
+func (q *QualityRules) measure(dataset string, received int, result *Result) []Metric {
+	var metrics []Metric
+	add := func(field, metric, category string, count, total int) {
+		if total == 0 {
+			return
+		}
+		metrics = append(metrics, Metric{
+			Field:    field,
+			Metric:   metric,
+			Category: category,
+			Value:    float64(count) / float64(total),
+			Count:    count,
+			Total:    total,
+		})
+	}
+
+	// Completeness and validity of every field
+	for _, name := range q.fields {
+		nulls := 0
+		for _, record := range result.Records {
+			if record[name] == nil {
+				nulls++
+			}
+		}
+		add(name, MetricNullRate, "", nulls, len(result.Records))
+		add(name, MetricParseFailureRate, "", result.ParseFailures[name], received)
+		add(name, MetricLossyRate, "", result.Lossy[name], received)
+	}
+
+	// Uniqueness of the key, counting the rows rejected as duplicates by a unique rule
+	if len(q.Keys) > 0 {
+		duplicates := 0
+		seen := make(map[string]bool, len(result.Records))
+		for _, record := range result.Records {
+			key, ok := uniqueKey(record, q.Keys)
+			if !ok {
+				continue
+			}
+			if seen[key] {
+				duplicates++
+			}
+			seen[key] = true
+		}
+		for _, rejection := range result.Rejected {
+			if rejection.Rule == "unique" {
+				duplicates++
+			}
+		}
+		add(strings.Join(q.Keys, ","), MetricDuplicateRate, "", duplicates, received)
+	}
+
+	// Distribution of the categorical fields. A category of the previous batches that is missing from
+	// this one gets a share of zero, so that its disappearance can raise an alert.
+	for _, name := range q.Categorical {
+		counts := make(map[string]int)
+		for _, record := range result.Records {
+			if value := record[name]; present(value) {
+				counts[fmt.Sprint(value)]++
+			}
+		}
+		for _, category := range q.history.categories(name) {
+			if _, ok := counts[category]; !ok {
+				counts[category] = 0
+			}
+		}
+		categories := make([]string, 0, len(counts))
+		for category := range counts {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		for _, category := range categories {
+			add(name, MetricValueShare, category, counts[category], len(result.Records))
+		}
+	}
+
+	// Compare with the previous batches and remember this one
+	for i := range metrics {
+		m := &metrics[i]
+		m.TrailingAverage = q.history.add(metricKey(m.Field, m.Metric, m.Category), m.Value)
+		threshold, ok := q.Thresholds[m.Metric]
+		if ok && m.TrailingAverage != nil && math.Abs(m.Value-*m.TrailingAverage) > threshold {
+			m.Alert = true
+			log.Printf("Data quality alert for %s: %s of %s%s is %.4f, trailing average %.4f, threshold %.4f",
+				dataset, m.Metric, m.Field, categorySuffix(m.Category), m.Value, *m.TrailingAverage, threshold)
+		}
+	}
+	return metrics
+}
+
+// categorySuffix formats the category of a metric for log messages
+func categorySuffix(category string) string {
+	if category == "" {
+		return ""
+	}
+	return fmt.Sprintf(" = %q", category)
+}
+
+// metricKey identifies a metric of a field, and its category for value_share, across batches
+func metricKey(field, metric, category string) string {
+	return field + "\x1f" + metric + "\x1f" + category
+}
+
+// metricHistory remembers the values of each metric over the last batches. Values are kept in memory
+// and seeded from the stored metrics by SetupQualityHistory, so trailing averages survive restarts.
+type metricHistory struct {
+	mu      sync.Mutex
+	batches int
+	values  map[string][]float64
+}
+
+func newMetricHistory(batches int) *metricHistory {
+	return &metricHistory{batches: batches, values: make(map[string][]float64)}
+}
+
+// add records the value of a metric and returns the average of its previous values, if any
+func (h *metricHistory) add(key string, value float64) *float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	previous := h.values[key]
+	var average *float64
+	if len(previous) > 0 {
+		sum := 0.0
+		for _, v := range previous {
+			sum += v
+		}
+		average = new(float64)
+		*average = sum / float64(len(previous))
+	}
+
+	h.record(key, value)
+	return average
+}
+
+// seed records a value of a metric measured before the cleaner started, oldest first
+func (h *metricHistory) seed(key string, value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record(key, value)
+}
+
+// record appends a value to the history of a metric, keeping the last batches. h.mu must be held.
+func (h *metricHistory) record(key string, value float64) {
+	values := append(h.values[key], value)
+	if len(values) > h.batches {
+		values = values[len(values)-h.batches:]
+	}
+	h.values[key] = values
+}
+
+// categories returns the categories of a field that had a non-zero value_share in the previous batches.
+// A category that stays missing for all of them is forgotten.
+func (h *metricHistory) categories(field string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	prefix := metricKey(field, MetricValueShare, "")
+	var categories []string
+	for key, values := range h.values {
+		category, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		for _, v := range values {
+			if v > 0 {
+				categories = append(categories, category)
+				break
+			}
+		}
+	}
+	return categories
+}
@@ -0,0 +1,314 @@
+package clean
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/community"
+)
+
+// Rules declares how the raw records of one dataset are cleaned
+type Rules struct {
+	Dataset    string        `json:"dataset"`
+	Fields     []FieldRule   `json:"fields"`
+	Derived    []DerivedRule `json:"derived"`
+	Validators []RecordRule  `json:"validators"`
+	Quality    QualityRules  `json:"quality"`
+}
+
+// FieldRule declares how one field of a cleaned record is read from the raw record
+type FieldRule struct {
+	Source     string      `json:"source"`     // Key in the raw record; dots select nested keys and array indexes
+	Name       string      `json:"name"`       // Name in the cleaned record, defaults to Source
+	Type       string      `json:"type"`       // string, int, float, bool or timestamp
+	Required   bool        `json:"required"`   // Drop the record when the value is missing or invalid
+	Default    interface{} `json:"default"`    // Value used for an optional field that is missing or invalid, null if not set
+	Validators []ValueRule `json:"validators"` // Checks applied to the parsed value
+}
+
+// DerivedRule declares a field computed from other fields of the cleaned record.
+// duration_seconds is the number of seconds from the first to the second timestamp field.
+// when_equal is the first field converted to Type when the second field equals Equals, and
+// null otherwise, which splits a field that holds values of several kinds.
+type DerivedRule struct {
+	Name       string      `json:"name"`       // Name in the cleaned record
+	Derive     string      `json:"derive"`     // duration_seconds or when_equal, see below
+	Fields     []string    `json:"fields"`     // Cleaned fields the value is computed from
+	Type       string      `json:"type"`       // Type the value is converted to by when_equal
+	Equals     string      `json:"equals"`     // Value of the condition field for when_equal
+	Validators []ValueRule `json:"validators"` // Checks applied to the computed value
+}
+
+// ValueRule is a check applied to a parsed field value
+type ValueRule struct {
+	Rule    string   `json:"rule"`    // not_empty, range, one_of or pattern
+	Min     *float64 `json:"min"`     // Inclusive lower bound for range
+	Max     *float64 `json:"max"`     // Inclusive upper bound for range
+	Values  []string `json:"values"`  // Allowed values for one_of
+	Pattern string   `json:"pattern"` // Regular expression for pattern
+
+	re *regexp.Regexp
+}
+
+// RecordRule is a check applied to a whole cleaned record
+type RecordRule struct {
+	Rule   string   `json:"rule"`   // any_present, within_chicago, chronological, community_area or unique
+	Fields []string `json:"fields"` // Cleaned field names the rule applies to, see below
+	Action string   `json:"action"` // reject (the default) drops the record, null sets the fields to null and keeps it
+	Window string   `json:"window"` // For unique, how long a key is remembered across batches, e.g. 24h
+
+	// within_chicago takes a latitude and a longitude field, chronological two timestamp
+	// fields that must be in order, and unique the fields that identify a record.
+	// community_area takes a number field and an optional name field, which is added to
+	// the record if it is not declared; it sets both to the canonical community area.
+
+	seen *seenKeys // Keys accepted by a unique rule
+}
+
+// Actions of a record rule on a failing record
+const (
+	actionReject = "reject"
+	actionNull   = "null"
+)
+
+// Field types supported by the rules
+var fieldTypes = map[string]bool{"string": true, "int": true, "float": true, "bool": true, "timestamp": true}
+
+//go:embed rules
+var bundledRules embed.FS
+
+var (
+	rulesOnce sync.Once
+	rulesSets map[string]*Rules
+	rulesErr  error
+)
+
+// loadRules returns the rules of every dataset, read from CLEANING_RULES_DIR or the bundled rules
+func loadRules() (map[string]*Rules, error) {
+	rulesOnce.Do(func() {
+		var fsys fs.FS
+		if dir := os.Getenv("CLEANING_RULES_DIR"); dir != "" {
+			fsys = os.DirFS(dir)
+		} else {
+			fsys, rulesErr = fs.Sub(bundledRules, "rules")
+			if rulesErr != nil {
+				return
+			}
+		}
+		rulesSets, rulesErr = readRules(fsys)
+	})
+	return rulesSets, rulesErr
+}
+
+// Datasets returns the names of the datasets that have cleaning rules
+func Datasets() ([]string, error) {
+	sets, err := loadRules()
+	if err != nil {
+		return nil, err
+	}
+	var datasets []string
+	for dataset := range sets {
+		datasets = append(datasets, dataset)
+	}
+	sort.Strings(datasets)
+	return datasets, nil
+}
+
+// readRules reads and checks every <dataset>.json file of fsys
+func readRules(fsys fs.FS) (map[string]*Rules, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cleaning rules: %w", err)
+	}
+
+	sets := make(map[string]*Rules)
+	for _, file := range files {
+		raw, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		var rules Rules
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		if rules.Dataset == "" {
+			rules.Dataset = strings.TrimSuffix(path.Base(file), ".json")
+		}
+		if err := rules.check(); err != nil {
+			return nil, fmt.Errorf("invalid rules in %s: %w", file, err)
+		}
+		sets[rules.Dataset] = &rules
+	}
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("no cleaning rules found")
+	}
+	return sets, nil
+}
+
+// check validates the rules and prepares them for use
+func (r *Rules) check() error {
+	types := make(map[string]string) // Type of each cleaned field
+	for i := range r.Fields {
+		f := &r.Fields[i]
+		if f.Source == "" {
+			return fmt.Errorf("field %d has no source", i)
+		}
+		if f.Name == "" {
+			f.Name = f.Source
+		}
+		if types[f.Name] != "" {
+			return fmt.Errorf("field %s is declared twice", f.Name)
+		}
+		if !fieldTypes[f.Type] {
+			return fmt.Errorf("field %s has unknown type %q", f.Name, f.Type)
+		}
+		types[f.Name] = f.Type
+
+		if err := checkValueRules(f.Name, f.Validators); err != nil {
+			return err
+		}
+
+		// The default of an optional field must itself be valid for the field type
+		if f.Default != nil {
+			if _, err := parseValue(f.Type, f.Default); err != nil {
+				return fmt.Errorf("field %s: invalid default: %w", f.Name, err)
+			}
+		}
+	}
+
+	for i := range r.Derived {
+		d := &r.Derived[i]
+		if d.Name == "" {
+			return fmt.Errorf("derived field %d has no name", i)
+		}
+		if types[d.Name] != "" {
+			return fmt.Errorf("field %s is declared twice", d.Name)
+		}
+		switch d.Derive {
+		case "duration_seconds":
+			if len(d.Fields) != 2 || types[d.Fields[0]] != "timestamp" || types[d.Fields[1]] != "timestamp" {
+				return fmt.Errorf("derived field %s needs two timestamp fields", d.Name)
+			}
+			types[d.Name] = "int"
+		case "when_equal":
+			if len(d.Fields) != 2 || types[d.Fields[0]] == "" || types[d.Fields[1]] == "" {
+				return fmt.Errorf("derived field %s needs a value field and a condition field", d.Name)
+			}
+			if d.Equals == "" {
+				return fmt.Errorf("derived field %s has no value to compare with", d.Name)
+			}
+			if !fieldTypes[d.Type] {
+				return fmt.Errorf("derived field %s has unknown type %q", d.Name, d.Type)
+			}
+			types[d.Name] = d.Type
+		default:
+			return fmt.Errorf("derived field %s has unknown derivation %q", d.Name, d.Derive)
+		}
+		if err := checkValueRules(d.Name, d.Validators); err != nil {
+			return err
+		}
+	}
+
+	for i := range r.Validators {
+		v := &r.Validators[i]
+		switch v.Rule {
+		case "any_present":
+		case "chronological":
+			if len(v.Fields) != 2 || types[v.Fields[0]] != "timestamp" || types[v.Fields[1]] != "timestamp" {
+				return fmt.Errorf("record validator %s needs two timestamp fields", v.Rule)
+			}
+		case "community_area":
+			if len(v.Fields) < 1 || len(v.Fields) > 2 {
+				return fmt.Errorf("record validator %s needs a number field and optionally a name field", v.Rule)
+			}
+			if t := types[v.Fields[0]]; t != "" && t != "int" && t != "string" {
+				return fmt.Errorf("record validator %s needs an int or string number field, %s is %s", v.Rule, v.Fields[0], t)
+			}
+			if len(v.Fields) == 2 {
+				switch types[v.Fields[1]] {
+				case "":
+					types[v.Fields[1]] = "string" // The name field is added by the rule
+				case "string":
+				default:
+					return fmt.Errorf("record validator %s needs a string name field, %s is not", v.Rule, v.Fields[1])
+				}
+			}
+			if _, err := community.Areas(); err != nil {
+				return err
+			}
+		case "unique":
+			if len(v.Fields) == 0 {
+				return fmt.Errorf("record validator %s needs at least one field", v.Rule)
+			}
+			var window time.Duration
+			if v.Window != "" {
+				var err error
+				if window, err = time.ParseDuration(v.Window); err != nil || window < 0 {
+					return fmt.Errorf("record validator %s has an invalid window %q", v.Rule, v.Window)
+				}
+			}
+			v.seen = newSeenKeys(window)
+		case "within_chicago":
+			if len(v.Fields) != 2 {
+				return fmt.Errorf("record validator %s needs a latitude and a longitude field", v.Rule)
+			}
+			for _, name := range v.Fields {
+				if types[name] != "float" {
+					return fmt.Errorf("record validator %s needs float fields, %s is not", v.Rule, name)
+				}
+			}
+		default:
+			return fmt.Errorf("unknown record validator %q", v.Rule)
+		}
+		for _, name := range v.Fields {
+			if types[name] == "" {
+				return fmt.Errorf("record validator %s refers to unknown field %s", v.Rule, name)
+			}
+		}
+
+		switch v.Action {
+		case "":
+			v.Action = actionReject
+		case actionReject:
+		case actionNull:
+			if v.Rule == "unique" {
+				return fmt.Errorf("record validator %s can only reject records", v.Rule)
+			}
+		default:
+			return fmt.Errorf("record validator %s has unknown action %q", v.Rule, v.Action)
+		}
+	}
+	return r.Quality.check(types)
+}
+
+// checkValueRules validates the value rules of a field and prepares them for use
+func checkValueRules(field string, rules []ValueRule) error {
+	for j := range rules {
+		v := &rules[j]
+		switch v.Rule {
+		case "not_empty", "one_of":
+		case "range":
+			if v.Min == nil && v.Max == nil {
+				return fmt.Errorf("field %s: range needs min or max", field)
+			}
+		case "pattern":
+			re, err := regexp.Compile(v.Pattern)
+			if err != nil {
+				return fmt.Errorf("field %s: invalid pattern: %w", field, err)
+			}
+			v.re = re
+		default:
+			return fmt.Errorf("field %s has unknown validator %q", field, v.Rule)
+		}
+	}
+	return nil
+}
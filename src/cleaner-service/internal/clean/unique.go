@@ -0,0 +1,97 @@
+package clean
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often expired keys are removed from a unique rule
+const pruneInterval = time.Minute
+
+// seenKeys remembers the keys of the rows accepted by a unique rule and the row they came from.
+// Keys are kept in memory, so duplicates are only detected within one cleaner process.
+type seenKeys struct {
+	mu     sync.Mutex
+	window time.Duration // How long a key is remembered across pages
+	keys   map[string]seenKey
+	pruned time.Time
+}
+
+// seenKey is the row that a key was first accepted from
+type seenKey struct {
+	page string // Hash of the raw page
+	row  int
+	at   time.Time
+}
+
+func newSeenKeys(window time.Duration) *seenKeys {
+	return &seenKeys{window: window, keys: make(map[string]seenKey)}
+}
+
+// find reports where the key of a row was seen before, if it is a duplicate, without recording it.
+// The same row of the same page is not a duplicate, so a redelivered page is cleaned again.
+func (s *seenKeys) find(key, page string, row int, now time.Time) (seenKey, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.duplicate(key, page, row, now)
+}
+
+// add records the key of an accepted row, unless it became a duplicate since it was checked,
+// such as when another page with the same key was accepted in the meantime
+func (s *seenKeys) add(key, page string, row int, now time.Time) (seenKey, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if seen, dup := s.duplicate(key, page, row, now); dup {
+		return seen, true
+	}
+	s.keys[key] = seenKey{page: page, row: row, at: now}
+	return seenKey{}, false
+}
+
+// duplicate reports where a key was seen before, if it is a duplicate, pruning expired keys first.
+// s.mu must be held.
+func (s *seenKeys) duplicate(key, page string, row int, now time.Time) (seenKey, bool) {
+	if now.Sub(s.pruned) >= pruneInterval {
+		for k, seen := range s.keys {
+			if now.Sub(seen.at) > s.window+pruneInterval {
+				delete(s.keys, k)
+			}
+		}
+		s.pruned = now
+	}
+
+	if seen, ok := s.keys[key]; ok {
+		samePage := seen.page == page
+		if samePage && seen.row != row {
+			return seen, true // Repeated within the page
+		}
+		if !samePage && now.Sub(seen.at) <= s.window {
+			return seen, true // Repeated within the window
+		}
+	}
+	return seenKey{}, false
+}
+
+// duplicateError describes the row a duplicate key was first seen in
+func duplicateError(fields []string, seen seenKey, page string) error {
+	if seen.page == page {
+		return fmt.Errorf("duplicate %s, first seen in row %d", strings.Join(fields, ", "), seen.row)
+	}
+	return fmt.Errorf("duplicate %s, first seen at %s", strings.Join(fields, ", "), seen.at.Format(time.RFC3339))
+}
+
+// uniqueKey joins the values of the fields that identify a record, or reports that one is missing
+func uniqueKey(record map[string]interface{}, fields []string) (string, bool) {
+	parts := make([]string, len(fields))
+	for i, name := range fields {
+		value := record[name]
+		if !present(value) {
+			return "", false
+		}
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, "\x1f"), true
+}
@@ -0,0 +1,38 @@
+package clean
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"shared/model"
+)
+
+// modelValidators check a cleaned record against the record type of its dataset, which the
+// transformer decodes it into. They catch records that rule files replaced at runtime let
+// through without the fields every downstream stage relies on.
+var modelValidators = map[string]func(record map[string]interface{}) error{
+	model.TaxiTrips:               validateAs[model.TaxiTrip],
+	model.CovidCases:              validateAs[model.CovidWeeklyCase],
+	model.CovidDailyCases:         validateAs[model.CovidDailyCase],
+	model.CovidVulnerabilityIndex: validateAs[model.CCVIEntry],
+	model.BuildingPermits:         validateAs[model.BuildingPermit],
+	model.CensusData:              validateAs[model.CensusArea],
+	model.TransportationTrips:     validateAs[model.TransportationTrip],
+	model.PublicHealthStatistics:  validateAs[model.PublicHealthStat],
+	model.Crimes:                  validateAs[model.Crime],
+	model.CTARidership:            validateAs[model.StationRidership],
+	model.BusinessLicenses:        validateAs[model.BusinessLicense],
+}
+
+// validateAs converts a cleaned record to the record type T and validates it
+func validateAs[T interface{ Validate() error }](record map[string]interface{}) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("record cannot be encoded: %w", err)
+	}
+	var typed T
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return fmt.Errorf("record does not match its model: %w", err)
+	}
+	return typed.Validate()
+}
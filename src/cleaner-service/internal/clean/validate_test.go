@@ -0,0 +1,87 @@
+package clean
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestModelValidatorsCoverDatasets(t *testing.T) {
+	datasets, err := Datasets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dataset := range datasets {
+		if _, ok := modelValidators[dataset]; !ok {
+			t.Errorf("dataset %s has cleaning rules but no model validator", dataset)
+		}
+	}
+}
+
+// TestCleanRecordChecksModel replaces the taxi trip rules by a file that leaves trip_id optional,
+// which the TaxiTrip model requires
+func TestCleanRecordChecksModel(t *testing.T) {
+	fsys := fstest.MapFS{"taxi_trips.json": &fstest.MapFile{Data: []byte(`{
+		"dataset": "taxi_trips",
+		"fields": [
+			{"source": "trip_id", "type": "string"},
+			{"source": "trip_start_timestamp", "type": "timestamp", "required": true},
+			{"source": "trip_end_timestamp", "type": "timestamp", "required": true}
+		]
+	}`)}}
+	sets, err := readRules(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []interface{}{
+		map[string]interface{}{"trip_id": "a", "trip_start_timestamp": "2023-06-01T08:15:00.000", "trip_end_timestamp": "2023-06-01T08:30:00.000"},
+		map[string]interface{}{"trip_start_timestamp": "2023-06-01T08:15:00.000", "trip_end_timestamp": "2023-06-01T08:30:00.000"},
+	}
+	result := sets["taxi_trips"].apply(rows, "validate-test")
+	if len(result.Records) != 1 || len(result.Rejected) != 1 {
+		t.Fatalf("apply() kept %d records and rejected %d, want 1 and 1", len(result.Records), len(result.Rejected))
+	}
+	if rejection := result.Rejected[0]; rejection.Row != 1 || rejection.Rule != ruleModel {
+		t.Errorf("rejected row %d by %q (%s), want row 1 by %q", rejection.Row, rejection.Rule, rejection.Reason, ruleModel)
+	}
+}
+
+// TestRejectedRowKeepsKeyFree checks that a row rejected by its model does not make a later
+// valid row with the same key a duplicate, within the page or in a later page
+func TestRejectedRowKeepsKeyFree(t *testing.T) {
+	fsys := fstest.MapFS{"taxi_trips.json": &fstest.MapFile{Data: []byte(`{
+		"dataset": "taxi_trips",
+		"fields": [
+			{"source": "trip_id", "type": "string", "required": true},
+			{"source": "trip_start_timestamp", "type": "timestamp"},
+			{"source": "trip_end_timestamp", "type": "timestamp"}
+		],
+		"validators": [{"rule": "unique", "fields": ["trip_id"], "window": "24h"}]
+	}`)}}
+	sets, err := readRules(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := sets["taxi_trips"]
+	invalid := map[string]interface{}{"trip_id": "a", "trip_start_timestamp": "2023-06-01T08:15:00.000"}
+	valid := map[string]interface{}{"trip_id": "a", "trip_start_timestamp": "2023-06-01T08:15:00.000", "trip_end_timestamp": "2023-06-01T08:30:00.000"}
+
+	result := rules.apply([]interface{}{invalid, valid, valid}, "unique-test-1")
+	if len(result.Records) != 1 || len(result.Rejected) != 2 {
+		t.Fatalf("apply() kept %d records and rejected %+v, want 1 and 2", len(result.Records), result.Rejected)
+	}
+	for i, want := range []struct {
+		row  int
+		rule string
+	}{{0, ruleModel}, {2, "unique"}} {
+		if rejection := result.Rejected[i]; rejection.Row != want.row || rejection.Rule != want.rule {
+			t.Errorf("rejected row %d by %q (%s), want row %d by %q", rejection.Row, rejection.Rule, rejection.Reason, want.row, want.rule)
+		}
+	}
+
+	// A key whose rows were all rejected stays free for a later page
+	rules.apply([]interface{}{map[string]interface{}{"trip_id": "b"}}, "unique-test-2")
+	if later := rules.apply([]interface{}{valid, map[string]interface{}{"trip_id": "b",
+		"trip_start_timestamp": "2023-06-01T09:00:00.000", "trip_end_timestamp": "2023-06-01T09:10:00.000"}}, "unique-test-3"); len(later.Records) != 1 || len(later.Rejected) != 1 || later.Rejected[0].Row != 0 {
+		t.Errorf("later page kept %d records and rejected %+v, want only the repeated a rejected", len(later.Records), later.Rejected)
+	}
+}
@@ -0,0 +1,11 @@
+package queue
+
+import (
+	"github.com/streadway/amqp"
+	sharedqueue "shared/queue"
+)
+
+// publishDeadLetter moves a message that crashed its consumer to the dead-letter queue of its queue
+func publishDeadLetter(queueName string, msg amqp.Delivery, cause *sharedqueue.PanicError) error {
+	return publish(queueName+sharedqueue.DeadLetterSuffix, sharedqueue.DeadLetter(queueName, msg, cause))
+}
@@ -0,0 +1,46 @@
+package queue
+
+import (
+	"cleaner-service/internal/clean"
+	"fmt"
+	"time"
+
+	"shared/model"
+	"shared/schema"
+)
+
+// publishQualityMetrics publishes the data quality metrics of a batch to the data quality queue
+func publishQualityMetrics(source, batchID string, result *clean.Result, now time.Time) error {
+	if len(result.Metrics) == 0 {
+		return nil
+	}
+
+	metrics := make([]model.QualityMetric, 0, len(result.Metrics))
+	for _, m := range result.Metrics {
+		metric := model.QualityMetric{
+			Batch_id:         batchID,
+			Dataset:          source,
+			Measured_at:      now,
+			Field_name:       model.Ptr(m.Field),
+			Metric:           m.Metric,
+			Value:            m.Value,
+			Count:            int64(m.Count),
+			Total:            int64(m.Total),
+			Trailing_average: m.TrailingAverage,
+			Alert:            m.Alert,
+		}
+		if m.Category != "" {
+			metric.Category = model.Ptr(m.Category)
+		}
+		metrics = append(metrics, metric)
+	}
+
+	message, err := schema.Marshal(schema.QualityMetrics, metrics)
+	if err != nil {
+		return fmt.Errorf("failed to encode data quality metrics: %w", err)
+	}
+	if err := PublishToQueue(model.QualityMetrics, message, schema.ContentType); err != nil {
+		return fmt.Errorf("failed to publish data quality metrics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"cleaner-service/internal/clean"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"shared/model"
+	"shared/schema"
+)
+
+// newBatchID returns a unique ID for one raw page, e.g. taxi_trips-20240131T120000Z-1a2b3c4d
+func newBatchID(source string, now time.Time) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s-%d", source, now.UnixNano())
+	}
+	return fmt.Sprintf("%s-%s-%s", source, now.UTC().Format("20060102T150405Z"), hex.EncodeToString(suffix))
+}
+
+// publishQuarantine publishes every rejected row of a batch to <source>_quarantine
+func publishQuarantine(source, batchID string, result *clean.Result, now time.Time) error {
+	if len(result.Rejected) == 0 {
+		return nil
+	}
+
+	records := make([]model.QuarantinedRecord, 0, len(result.Rejected))
+	for _, rejection := range result.Rejected {
+		raw, _ := json.Marshal(rejection.Record) // Decoded from JSON, so it always encodes
+		record := model.QuarantinedRecord{
+			Batch_id:    batchID,
+			Dataset:     source,
+			Row_index:   int64(rejection.Row),
+			Rule:        rejection.Rule,
+			Reason:      rejection.Reason,
+			Raw_record:  string(raw),
+			Rejected_at: now,
+		}
+		if rejection.Field != "" {
+			record.Field_name = model.Ptr(rejection.Field)
+		}
+		records = append(records, record)
+	}
+
+	message, err := schema.Marshal(schema.Quarantine, records)
+	if err != nil {
+		return fmt.Errorf("failed to encode quarantined records: %w", err)
+	}
+	quarantineQueueName := source + "_quarantine"
+	if err := PublishToQueue(quarantineQueueName, message, schema.ContentType); err != nil {
+		return fmt.Errorf("failed to publish quarantined records: %w", err)
+	}
+	log.Printf("Quarantined %d %s records of batch %s", len(records), source, batchID)
+	return nil
+}
+
+// publishDropSummary publishes the number of rows of a batch dropped for each field and rule
+func publishDropSummary(source, batchID string, result *clean.Result, now time.Time) error {
+	type reason struct{ field, rule string }
+	counts := make(map[reason]int64)
+	for _, rejection := range result.Rejected {
+		counts[reason{rejection.Field, rejection.Rule}]++
+	}
+
+	base := model.DropSummary{
+		Batch_id:         batchID,
+		Dataset:          source,
+		Cleaned_at:       now,
+		Received_records: int64(len(result.Records) + len(result.Rejected)),
+		Cleaned_records:  int64(len(result.Records)),
+	}
+	summaries := []model.DropSummary{base}
+	if len(counts) > 0 {
+		summaries = summaries[:0]
+		for r, count := range counts {
+			summary := base
+			if r.field != "" {
+				summary.Field_name = model.Ptr(r.field)
+			}
+			summary.Rule = model.Ptr(r.rule)
+			summary.Dropped_records = count
+			summaries = append(summaries, summary)
+		}
+		sort.Slice(summaries, func(i, j int) bool {
+			return summaries[i].Dropped_records > summaries[j].Dropped_records
+		})
+	}
+
+	message, err := schema.Marshal(schema.DropSummary, summaries)
+	if err != nil {
+		return fmt.Errorf("failed to encode drop summary: %w", err)
+	}
+	if err := PublishToQueue(model.DropSummaries, message, schema.ContentType); err != nil {
+		return fmt.Errorf("failed to publish drop summary: %w", err)
+	}
+	return nil
+}
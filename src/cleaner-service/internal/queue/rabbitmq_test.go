@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"shared/model"
+	"shared/schema"
+)
+
+// A page of two taxi trips, the second of which is rejected for its missing end
+const taxiTripsPage = `{"data": [
+	{"trip_id": "publish-test-%[1]s-1", "trip_start_timestamp": "2023-06-01T08:15:00.000", "trip_end_timestamp": "2023-06-01T08:30:00.000",
+	 "pickup_centroid_latitude": "41.899602111", "pickup_centroid_longitude": "-87.633308037", "pickup_community_area": "8",
+	 "dropoff_centroid_latitude": "41.880994471", "dropoff_centroid_longitude": "-87.632746489", "dropoff_community_area": "32"},
+	{"trip_id": "publish-test-%[1]s-2", "trip_start_timestamp": "2023-06-01T09:00:00.000",
+	 "pickup_centroid_latitude": "41.899602111", "pickup_centroid_longitude": "-87.633308037", "pickup_community_area": "8",
+	 "dropoff_centroid_latitude": "41.880994471", "dropoff_centroid_longitude": "-87.632746489", "dropoff_community_area": "32"}
+]}`
+
+// fakePublisher records the messages published in place of RabbitMQ, failing those sent to failQueue
+type fakePublisher struct {
+	failQueue string
+	queues    []string
+	messages  map[string]amqp.Publishing
+}
+
+func (p *fakePublisher) publish(queueName string, msg amqp.Publishing) error {
+	if queueName == p.failQueue {
+		return errors.New("connection reset by peer")
+	}
+	p.queues = append(p.queues, queueName)
+	p.messages[queueName] = msg
+	return nil
+}
+
+// withPublisher replaces publish for the duration of a test
+func withPublisher(t *testing.T, failQueue string) *fakePublisher {
+	p := &fakePublisher{failQueue: failQueue, messages: make(map[string]amqp.Publishing)}
+	original := publish
+	publish = p.publish
+	t.Cleanup(func() { publish = original })
+	return p
+}
+
+func TestProcessMessagePublishesReportsBeforeBronze(t *testing.T) {
+	p := withPublisher(t, "")
+	if err := ProcessMessage([]byte(fmt.Sprintf(taxiTripsPage, "order")), "taxi_trips_raw"); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	want := []string{"taxi_trips_quarantine", model.DropSummaries, model.QualityMetrics, "taxi_trips_bronze"}
+	if fmt.Sprint(p.queues) != fmt.Sprint(want) {
+		t.Fatalf("published to %v, want %v", p.queues, want)
+	}
+
+	// Every message of the batch carries the same batch ID
+	batchIDs := make(map[interface{}]bool)
+	for _, queueName := range want[:3] {
+		_, records, err := schema.Decode(p.messages[queueName].Body)
+		if err != nil {
+			t.Fatalf("invalid message on %s: %v", queueName, err)
+		}
+		for _, record := range records {
+			batchIDs[record["batch_id"]] = true
+		}
+	}
+	if len(batchIDs) != 1 {
+		t.Errorf("batch IDs = %v, want one", batchIDs)
+	}
+
+	_, records, err := schema.Decode(p.messages["taxi_trips_bronze"].Body)
+	if err != nil || len(records) != 1 {
+		t.Errorf("bronze message = %d records, %v, want the cleaned trip", len(records), err)
+	}
+}
+
+func TestProcessMessageWithholdsBronzeWhenReportsFail(t *testing.T) {
+	for _, failQueue := range []string{"taxi_trips_quarantine", model.DropSummaries, model.QualityMetrics} {
+		t.Run(failQueue, func(t *testing.T) {
+			p := withPublisher(t, failQueue)
+			err := ProcessMessage([]byte(fmt.Sprintf(taxiTripsPage, failQueue)), "taxi_trips_raw")
+			if err == nil {
+				t.Fatal("ProcessMessage() error = nil, want the publishing error")
+			}
+			if _, ok := p.messages["taxi_trips_bronze"]; ok {
+				t.Errorf("published the cleaned records although the %s message failed", failQueue)
+			}
+		})
+	}
+}
+
+func TestProcessMessageReportsBronzeFailure(t *testing.T) {
+	p := withPublisher(t, "taxi_trips_bronze")
+	if err := ProcessMessage([]byte(fmt.Sprintf(taxiTripsPage, "bronze")), "taxi_trips_raw"); err == nil {
+		t.Fatal("ProcessMessage() error = nil, want the publishing error")
+	}
+	if _, ok := p.messages["taxi_trips_quarantine"]; !ok {
+		t.Error("the rejected row was not quarantined")
+	}
+}
+
+func TestProcessMessageSkipsEmptyPage(t *testing.T) {
+	p := withPublisher(t, "")
+	if err := ProcessMessage([]byte(`{"data": null}`), "taxi_trips_raw"); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	if len(p.queues) != 0 {
+		t.Errorf("published to %v, want nothing", p.queues)
+	}
+}
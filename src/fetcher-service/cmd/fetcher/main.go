@@ -1,143 +1,122 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fetcher-service/internal/fetch"
 	"fetcher-service/internal/queue"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
+
+	"shared/model"
 )
 
 func main() {
+	// Cancel the root context on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Define the limit for the APIs
 	limit := 500
 
 	// Dictionary with table names as keys and base URLs as values
 	baseURLs := map[string]string{
-		"taxi_trips":                "https://data.cityofchicago.org/resource/wrvz-psew.json?$limit=%d&$offset=%d",
-		"covid_cases":               "https://data.cityofchicago.org/resource/yhhz-zm2v.json?$limit=%d&$offset=%d",
-		"building_permits":          "https://data.cityofchicago.org/resource/ydr8-5enu.json?$limit=%d&$offset=%d",
-		"transportation_trips":      "https://data.cityofchicago.org/resource/m6dm-c72p.json?$limit=%d&$offset=%d",
+		model.TaxiTrips:           "https://data.cityofchicago.org/resource/wrvz-psew.json?$limit=%d&$offset=%d",
+		model.CovidCases:          "https://data.cityofchicago.org/resource/yhhz-zm2v.json?$order=week_start,zip_code&$limit=%d&$offset=%d", // Oldest weeks first, see the COVID-19 trend
+		model.CovidDailyCases:     "https://data.cityofchicago.org/resource/naqz-ujwz.json?$limit=%d&$offset=%d",
+		model.BuildingPermits:     "https://data.cityofchicago.org/resource/ydr8-5enu.json?$limit=%d&$offset=%d",
+		model.TransportationTrips: "https://data.cityofchicago.org/resource/m6dm-c72p.json?$limit=%d&$offset=%d",
+		model.Crimes:              "https://data.cityofchicago.org/resource/ijzp-q8t2.json?$limit=%d&$offset=%d",
+		model.CTARidership:        "https://data.cityofchicago.org/resource/5neh-572f.json?$limit=%d&$offset=%d",
+		model.BusinessLicenses:    "https://data.cityofchicago.org/resource/uupf-x98q.json?$limit=%d&$offset=%d",
 	}
 
 	onceURLs := map[string]string{
-		"covid_vulnerability_index": "https://data.cityofchicago.org/resource/xhc6-88s9.json?$limit=%d&$offset=%d",
-		"census_data":               "https://data.cityofchicago.org/resource/kn9c-c2s2.json?$limit=%d&$offset=%d",
-		"public_health_statistics":  "https://data.cityofchicago.org/resource/iqnk-2tcu.json?$limit=%d&$offset=%d",
+		model.CovidVulnerabilityIndex: "https://data.cityofchicago.org/resource/xhc6-88s9.json?$limit=%d&$offset=%d",
+		model.CensusData:              "https://data.cityofchicago.org/resource/kn9c-c2s2.json?$limit=%d&$offset=%d",
+		model.PublicHealthStatistics:  "https://data.cityofchicago.org/resource/iqnk-2tcu.json?$limit=%d&$offset=%d",
 	}
 
 	// This only loops once
+	fetchAndPublish(ctx, onceURLs, limit, 0)
+
+	offset := 0 // Start from the first page
+	// Repeat the process 20 times
+	for i := 0; i < 20 && ctx.Err() == nil; i++ {
+		fetchAndPublish(ctx, baseURLs, limit, offset)
+
+		// Increment the offset for the next page
+		offset += limit
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second * 60):
+		}
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("Shutdown signal received, stopped fetching at offset %d", offset)
+		stop()
+		os.Exit(0)
+	}
+	log.Printf("Finished fetching all pages")
+}
+
+// fetchAndPublish fetches one page from every URL and publishes it to RabbitMQ.
+// It returns once every fetched page has been published, so no message is lost on shutdown.
+func fetchAndPublish(ctx context.Context, urls map[string]string, limit, offset int) {
 	// Create a channel to receive data from the goroutines
 	dataChan := make(chan map[string]interface{})
 	var wg sync.WaitGroup
 
 	// Start a new goroutine for each URL
-	for tableName, baseURL := range onceURLs {
-		offset := 0 // Start from the first page
+	for tableName, baseURL := range urls {
 		wg.Add(1)
 		go func(tableName, baseURL string) {
 			defer wg.Done()
 			ch := make(chan map[string]interface{})
 			// Construct the URL with the current offset
 			url := fmt.Sprintf(baseURL, limit, offset)
-			go fetch.FetchData(url, ch)
+			go fetch.FetchData(ctx, url, ch)
 
-			data := <-ch
+			data, ok := <-ch
+			if !ok {
+				return // The fetch failed or was cancelled
+			}
 			data["table_name"] = tableName // Add table name to the data
 			dataChan <- data
-
-			// Increment the offset for the next page
-			offset += limit
 		}(tableName, baseURL)
 	}
 
-	// Collect data from the dataChan and publish to RabbitMQ
-	go func() {
-		for data := range dataChan {
-			log.Printf("Received data for table %s: %v", data["table_name"], data["data"])
-
-			// Convert data to JSON
-			message, err := json.Marshal(data)
-			if err != nil {
-				log.Printf("Failed to marshal data: %v", err)
-				continue
-			}
-
-			queue_name := data["table_name"].(string) + "_raw"
-			log.Printf("Publishing data to queue: %s", queue_name)
-
-			// Publish data to RabbitMQ
-			err = queue.PublishToQueue(queue_name, message)
-			if err != nil {
-				log.Printf("Failed to publish data to queue: %v", err)
-			}
-		}
-	}()
-
 	// Wait for all goroutines to complete
 	go func() {
 		wg.Wait()
 		close(dataChan)
 	}()
 
-	offset := 0 // Start from the first page
-	// Repeat the process 5 times
-	for i := 0; i < 20; i++ {
-		// Create a channel to receive data from the goroutines
-		dataChan := make(chan map[string]interface{})
-		var wg sync.WaitGroup
-
-		// Start a new goroutine for each URL
-		for tableName, baseURL := range baseURLs {
-			wg.Add(1)
-			go func(tableName, baseURL string) {
-				defer wg.Done()
-				ch := make(chan map[string]interface{})
-				// Construct the URL with the current offset
-				url := fmt.Sprintf(baseURL, limit, offset)
-				go fetch.FetchData(url, ch)
-
-				data := <-ch
-				data["table_name"] = tableName // Add table name to the data
-				dataChan <- data
-
-				// Increment the offset for the next page
-				offset += limit
-			}(tableName, baseURL)
+	// Collect data from the dataChan and publish to RabbitMQ
+	for data := range dataChan {
+		log.Printf("Received data for table %s: %v", data["table_name"], data["data"])
+
+		// Convert data to JSON
+		message, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("Failed to marshal data: %v", err)
+			continue
 		}
 
-		// Collect data from the dataChan and publish to RabbitMQ
-		go func() {
-			for data := range dataChan {
-				log.Printf("Received data for table %s: %v", data["table_name"], data["data"])
-
-				// Convert data to JSON
-				message, err := json.Marshal(data)
-				if err != nil {
-					log.Printf("Failed to marshal data: %v", err)
-					continue
-				}
-
-				queue_name := data["table_name"].(string) + "_raw"
-				log.Printf("Publishing data to queue: %s", queue_name)
-
-				// Publish data to RabbitMQ
-				err = queue.PublishToQueue(queue_name, message)
-				if err != nil {
-					log.Printf("Failed to publish data to queue: %v", err)
-				}
-			}
-		}()
-
-		// Wait for all goroutines to complete
-		go func() {
-			wg.Wait()
-			close(dataChan)
-		}()
+		queue_name := data["table_name"].(string) + "_raw"
+		log.Printf("Publishing data to queue: %s", queue_name)
 
-		time.Sleep(time.Second * 60)
+		// Publish data to RabbitMQ
+		err = queue.PublishToQueue(queue_name, message)
+		if err != nil {
+			log.Printf("Failed to publish data to queue: %v", err)
+		}
 	}
 }
@@ -1,5 +1,13 @@
 module fetcher-service
 
-go 1.23
+go 1.23.4
 
-require github.com/streadway/amqp v1.1.0
+require (
+	github.com/streadway/amqp v1.1.0
+	shared v0.0.0
+)
+
+require github.com/klauspost/compress v1.17.11 // indirect
+
+// The shared module is built from the sibling directory
+replace shared => ../shared
@@ -1,6 +1,7 @@
 package fetch
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"log"
@@ -8,14 +9,23 @@ import (
 	"time"
 )
 
-func FetchData(url string, ch chan<- map[string]interface{}) {
+// FetchData fetches one page of JSON records from url and sends it over ch.
+// The channel is always closed, without a value if the request failed or ctx was cancelled.
+func FetchData(ctx context.Context, url string, ch chan<- map[string]interface{}) {
+	defer close(ch) // Close the channel after sending data
+
 	// The Taxi Trips dataset takes a long time to fetch, so this may need to be increased
 	client := &http.Client{
 		Timeout: 300 * time.Second, // Set a timeout for the HTTP request
 	}
 
-	// Fetch data from the URL
-	resp, err := client.Get(url)
+	// Fetch data from the URL, aborting if the service is shutting down
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		log.Printf("Error creating request for %s: %v", url, err)
+		return
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		log.Printf("Error fetching data from %s: %v", url, err)
 		return
@@ -48,5 +58,4 @@ func FetchData(url string, ch chan<- map[string]interface{}) {
 	} else {
 		ch <- map[string]interface{}{"url": url, "data": nil}
 	}
-	close(ch) // Close the channel after sending data
 }
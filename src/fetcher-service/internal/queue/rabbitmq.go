@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/streadway/amqp"
+	sharedqueue "shared/queue"
 )
 
 // RabbitMQConfig holds the configuration for RabbitMQ
@@ -55,6 +56,9 @@ func PublishToQueue(queueName string, message []byte) error {
 		return fmt.Errorf("failed to declare queue: %w", err)
 	}
 
+	// Compress large messages if enabled
+	body, contentEncoding := sharedqueue.Compress(queueName, message)
+
 	// Publish the message to the queue
 	err = ch.Publish(
 		"",        // exchange
@@ -62,8 +66,9 @@ func PublishToQueue(queueName string, message []byte) error {
 		false,     // mandatory
 		false,     // immediate
 		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        message,
+			ContentType:     "application/json",
+			ContentEncoding: contentEncoding,
+			Body:            body,
 		},
 	)
 	if err != nil {
@@ -0,0 +1,111 @@
+// Package community holds the canonical table of Chicago's 77 community areas,
+// bundled with the package, and resolves the numbers and names the datasets use for them.
+package community
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//go:embed community_areas.json
+var areasJSON []byte
+
+// Area is one of Chicago's community areas
+type Area struct {
+	Number  int64    `json:"number"`
+	Name    string   `json:"name"`    // Official name
+	Aliases []string `json:"aliases"` // Other names found in the datasets
+}
+
+var (
+	loadOnce sync.Once
+	areas    []Area
+	byNumber map[int64]Area
+	byName   map[string]Area // Keyed by nameKey of the name and every alias
+	loadErr  error
+)
+
+// load reads the bundled table and indexes it by number and name
+func load() error {
+	loadOnce.Do(func() {
+		if err := json.Unmarshal(areasJSON, &areas); err != nil {
+			loadErr = fmt.Errorf("failed to read community areas: %w", err)
+			return
+		}
+		byNumber = make(map[int64]Area, len(areas))
+		byName = make(map[string]Area, len(areas))
+		for _, area := range areas {
+			byNumber[area.Number] = area
+			for _, name := range append([]string{area.Name}, area.Aliases...) {
+				byName[nameKey(name)] = area
+			}
+		}
+	})
+	return loadErr
+}
+
+// Areas returns every community area, ordered by number
+func Areas() ([]Area, error) {
+	if err := load(); err != nil {
+		return nil, err
+	}
+	return areas, nil
+}
+
+// ByNumber returns the community area with the given number
+func ByNumber(number int64) (Area, bool) {
+	if err := load(); err != nil {
+		return Area{}, false
+	}
+	area, ok := byNumber[number]
+	return area, ok
+}
+
+// ByName returns the community area with the given official name or alias,
+// ignoring case, spaces and punctuation
+func ByName(name string) (Area, bool) {
+	if err := load(); err != nil {
+		return Area{}, false
+	}
+	area, ok := byName[nameKey(name)]
+	return area, ok
+}
+
+// Lookup resolves a value from a dataset, either a number such as 8, "8", "08" or "8.0", or a name
+func Lookup(value interface{}) (Area, bool) {
+	switch v := value.(type) {
+	case int64:
+		return ByNumber(v)
+	case int:
+		return ByNumber(int64(v))
+	case float64:
+		if v != float64(int64(v)) {
+			return Area{}, false
+		}
+		return ByNumber(int64(v))
+	case string:
+		s := strings.TrimSpace(v)
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return Lookup(f)
+		}
+		return ByName(s)
+	default:
+		return Area{}, false
+	}
+}
+
+// nameKey normalizes a name to its lower case letters and digits
+func nameKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
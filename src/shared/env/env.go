@@ -0,0 +1,37 @@
+// Package env reads the numeric settings of the services from the environment,
+// logging and ignoring invalid values rather than failing.
+package env
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+// Int reads a positive integer from the environment, falling back to def
+func Int(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Printf("Invalid value for %s: %q, using %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// Float reads a non-negative number from the environment, falling back to def
+func Float(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		log.Printf("Invalid value for %s: %q, using %g", key, value, def)
+		return def
+	}
+	return f
+}
@@ -0,0 +1,48 @@
+package env
+
+import "testing"
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 7},
+		{"12", 12},
+		{"1", 1},
+		{"0", 7},
+		{"-3", 7},
+		{"twelve", 7},
+		{"1.5", 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("ENV_TEST_INT", tt.value)
+			if got := Int("ENV_TEST_INT", 7); got != tt.want {
+				t.Errorf("Int(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloat(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"", 0.5},
+		{"0", 0},
+		{"0.25", 0.25},
+		{"100", 100},
+		{"-0.1", 0.5},
+		{"high", 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("ENV_TEST_FLOAT", tt.value)
+			if got := Float("ENV_TEST_FLOAT", 0.5); got != tt.want {
+				t.Errorf("Float(%q) = %g, want %g", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+// Package geo answers point-in-polygon questions offline, from GeoJSON boundaries such as
+// the City of Chicago's boundary and zip codes, and the airport geofences bundled with the package.
+package geo
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+//go:embed airports.geojson
+var airportsGeoJSON []byte
+
+// Feature is a GeoJSON feature with a Polygon or MultiPolygon geometry
+type Feature struct {
+	Properties map[string]interface{}
+
+	polygons [][][][2]float64 // Polygons, each a list of rings of [longitude, latitude] points
+	bbox     [4]float64       // Bounding box: min longitude, min latitude, max longitude, max latitude
+}
+
+// ReadFeatures parses a GeoJSON FeatureCollection or Feature.
+// Features without a Polygon or MultiPolygon geometry are skipped.
+func ReadFeatures(data []byte) ([]*Feature, error) {
+	var doc struct {
+		Type     string            `json:"type"`
+		Features []json.RawMessage `json:"features"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse GeoJSON: %w", err)
+	}
+
+	raws := doc.Features
+	switch doc.Type {
+	case "FeatureCollection":
+	case "Feature":
+		raws = []json.RawMessage{data}
+	default:
+		return nil, fmt.Errorf("unsupported GeoJSON type %q", doc.Type)
+	}
+
+	var features []*Feature
+	for i, raw := range raws {
+		var f struct {
+			Properties map[string]interface{} `json:"properties"`
+			Geometry   struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		}
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+
+		feature := &Feature{Properties: f.Properties}
+		switch f.Geometry.Type {
+		case "Polygon":
+			var polygon [][][2]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &polygon); err != nil {
+				return nil, fmt.Errorf("feature %d: invalid polygon: %w", i, err)
+			}
+			feature.polygons = [][][][2]float64{polygon}
+		case "MultiPolygon":
+			if err := json.Unmarshal(f.Geometry.Coordinates, &feature.polygons); err != nil {
+				return nil, fmt.Errorf("feature %d: invalid multipolygon: %w", i, err)
+			}
+		default:
+			continue
+		}
+		feature.bbox = boundingBox(feature.polygons)
+		features = append(features, feature)
+	}
+	return features, nil
+}
+
+// Contains reports whether the point lies inside the feature, outside any of its holes
+func (f *Feature) Contains(lat, lon float64) bool {
+	if lon < f.bbox[0] || lat < f.bbox[1] || lon > f.bbox[2] || lat > f.bbox[3] {
+		return false
+	}
+	for _, polygon := range f.polygons {
+		// Even-odd rule over the outer ring and its holes
+		inside := false
+		for _, ring := range polygon {
+			if ringContains(ring, lat, lon) {
+				inside = !inside
+			}
+		}
+		if inside {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains casts a ray from the point and counts the ring edges it crosses
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// boundingBox returns the extent of a set of polygons
+func boundingBox(polygons [][][][2]float64) [4]float64 {
+	box := [4]float64{180, 90, -180, -90}
+	for _, polygon := range polygons {
+		for _, ring := range polygon {
+			for _, p := range ring {
+				box[0] = min(box[0], p[0])
+				box[1] = min(box[1], p[1])
+				box[2] = max(box[2], p[0])
+				box[3] = max(box[3], p[1])
+			}
+		}
+	}
+	return box
+}
+
+// Extent of the City of Chicago padded by about a kilometre: min longitude, min latitude,
+// max longitude, max latitude
+var chicagoExtent = [4]float64{-87.951, 41.634, -87.514, 42.033}
+
+// chicago is the boundary set with SetChicago, or nil to check points against the extent
+var chicago atomic.Pointer[Feature]
+
+// ReadChicagoFile reads the boundary of the City of Chicago from a GeoJSON file, such as the export
+// of the data portal's Boundaries - City dataset (ewy2-6yfk). The polygons of all its features
+// make up the boundary.
+func ReadChicagoFile(path string) (*Feature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Chicago boundary: %w", err)
+	}
+	features, err := ReadFeatures(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(features) == 0 {
+		return nil, fmt.Errorf("%s: no Chicago boundary found", path)
+	}
+	boundary := &Feature{Properties: features[0].Properties}
+	for _, f := range features {
+		boundary.polygons = append(boundary.polygons, f.polygons...)
+	}
+	boundary.bbox = boundingBox(boundary.polygons)
+	return boundary, nil
+}
+
+// SetChicago makes InChicago check points against boundary, or against the extent of the city if
+// boundary is nil
+func SetChicago(boundary *Feature) {
+	chicago.Store(boundary)
+}
+
+// InChicago reports whether a point lies within the boundary of the City of Chicago set with
+// SetChicago. Without one, it reports whether the point lies within the extent of the city padded
+// by about a kilometre, which catches zeroed, swapped and otherwise misplaced coordinates but not
+// points in the suburbs along the city limits.
+func InChicago(lat, lon float64) bool {
+	if boundary := chicago.Load(); boundary != nil {
+		return boundary.Contains(lat, lon)
+	}
+	return lon >= chicagoExtent[0] && lat >= chicagoExtent[1] && lon <= chicagoExtent[2] && lat <= chicagoExtent[3]
+}
+
+var (
+	airportsOnce sync.Once
+	airports     []*Feature
+	airportsErr  error
+)
+
+// airportGeofences holds the geofences set with SetAirports, or nil to use the bundled ones
+var airportGeofences atomic.Pointer[[]*Feature]
+
+// Airports returns the bundled geofences of O'Hare and Midway airports, with the IATA code of
+// the airport in their code property. Each geofence covers the terminals, concourses and landside
+// of its airport (curbs, garages, rental cars and transit stations) rather than the airfield;
+// the parts property lists what it covers.
+func Airports() ([]*Feature, error) {
+	airportsOnce.Do(func() {
+		airports, airportsErr = readAirports(airportsGeoJSON)
+		if airportsErr != nil {
+			airportsErr = fmt.Errorf("failed to read the bundled airport geofences: %w", airportsErr)
+		}
+	})
+	return airports, airportsErr
+}
+
+// ReadAirportsFile reads airport geofences from a GeoJSON file, such as terminal and landside
+// polygons exported from a survey. Every feature must carry the IATA code of its airport in
+// its code property.
+func ReadAirportsFile(path string) ([]*Feature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read airport geofences: %w", err)
+	}
+	geofences, err := readAirports(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return geofences, nil
+}
+
+// SetAirports makes AirportAt look points up in geofences, or in the bundled geofences if geofences is nil
+func SetAirports(geofences []*Feature) {
+	if geofences == nil {
+		airportGeofences.Store(nil)
+		return
+	}
+	airportGeofences.Store(&geofences)
+}
+
+// AirportAt returns the IATA code of the airport whose geofence contains the point, among the
+// geofences set with SetAirports or else the bundled ones. If the bundled geofences cannot be
+// read, which Airports reports, no point is at an airport.
+func AirportAt(lat, lon float64) (string, bool) {
+	var geofences []*Feature
+	if set := airportGeofences.Load(); set != nil {
+		geofences = *set
+	} else {
+		geofences, _ = Airports()
+	}
+	for _, geofence := range geofences {
+		if geofence.Contains(lat, lon) {
+			return geofence.Properties["code"].(string), true
+		}
+	}
+	return "", false
+}
+
+// readAirports parses airport geofences and checks that each has the code of its airport
+func readAirports(data []byte) ([]*Feature, error) {
+	geofences, err := ReadFeatures(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(geofences) == 0 {
+		return nil, fmt.Errorf("no airport geofences found")
+	}
+	for i, geofence := range geofences {
+		if code, _ := geofence.Properties["code"].(string); code == "" {
+			return nil, fmt.Errorf("airport geofence %d has no code property", i)
+		}
+	}
+	return geofences, nil
+}
@@ -0,0 +1,203 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInChicagoExtent(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     bool
+	}{
+		{"Loop", 41.8819, -87.6278, true},
+		{"O'Hare terminals", 41.9786, -87.9048, true},
+		{"Hegewisch", 41.6550, -87.5480, true},
+		{"Rogers Park", 42.0150, -87.6700, true},
+		{"Null Island", 0, 0, false},
+		{"swapped latitude and longitude", -87.6278, 41.8819, false},
+		{"latitude without its sign", 41.8819, 87.6278, false},
+		{"Milwaukee", 43.0389, -87.9065, false},
+		{"Gary, Indiana", 41.6020, -87.3372, false},
+		{"Naperville", 41.7508, -88.1535, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InChicago(tt.lat, tt.lon); got != tt.want {
+				t.Errorf("InChicago(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInChicagoBoundary(t *testing.T) {
+	// Two squares, the first with a square hole, in two features
+	path := filepath.Join(t.TempDir(), "city.geojson")
+	data := `{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{"name":"CHICAGO"},"geometry":{"type":"MultiPolygon","coordinates":[
+			[[[-87.8,41.8],[-87.6,41.8],[-87.6,42.0],[-87.8,42.0],[-87.8,41.8]],[[-87.75,41.85],[-87.7,41.85],[-87.7,41.9],[-87.75,41.9],[-87.75,41.85]]]]}},
+		{"type":"Feature","properties":{"name":"CHICAGO"},"geometry":{"type":"Polygon","coordinates":[
+			[[-87.95,41.95],[-87.85,41.95],[-87.85,42.0],[-87.95,42.0],[-87.95,41.95]]]}}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	boundary, err := ReadChicagoFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetChicago(boundary)
+	t.Cleanup(func() { SetChicago(nil) })
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     bool
+	}{
+		{"first polygon", 41.82, -87.65, true},
+		{"hole", 41.87, -87.72, false},
+		{"second feature", 41.97, -87.9, true},
+		{"between the polygons, within the extent", 41.97, -87.82, false},
+	}
+	for _, tt := range tests {
+		if got := InChicago(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("%s: InChicago(%v, %v) = %v, want %v", tt.name, tt.lat, tt.lon, got, tt.want)
+		}
+	}
+
+	if _, err := ReadChicagoFile(filepath.Join(t.TempDir(), "missing.geojson")); err == nil {
+		t.Error("ReadChicagoFile() of a missing file error = nil, want an error")
+	}
+	empty := filepath.Join(t.TempDir(), "empty.geojson")
+	if err := os.WriteFile(empty, []byte(`{"type":"FeatureCollection","features":[]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadChicagoFile(empty); err == nil {
+		t.Error("ReadChicagoFile() without polygons error = nil, want an error")
+	}
+}
+
+// TestInChicagoOfficialBoundary checks real points on either side of the city limits against the
+// City Boundary dataset, exported from the data portal to the GeoJSON file in TEST_CITY_BOUNDARY:
+// curl -o city_boundary.geojson 'https://data.cityofchicago.org/api/geospatial/ewy2-6yfk?method=export&format=GeoJSON'
+func TestInChicagoOfficialBoundary(t *testing.T) {
+	path := os.Getenv("TEST_CITY_BOUNDARY")
+	if path == "" {
+		t.Skip("TEST_CITY_BOUNDARY is not set")
+	}
+	boundary, err := ReadChicagoFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetChicago(boundary)
+	t.Cleanup(func() { SetChicago(nil) })
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     bool
+	}{
+		// Austin Boulevard, the border with Oak Park, about 200 m on either side
+		{"Austin, east of Austin Boulevard", 41.8881, -87.7720, true},
+		{"Oak Park, west of Austin Boulevard", 41.8881, -87.7775, false},
+		// Howard Street, the border with Evanston, about 250 m on either side
+		{"Rogers Park, south of Howard Street", 42.0170, -87.6780, true},
+		{"Evanston, north of Howard Street", 42.0215, -87.6780, false},
+		// O'Hare and the suburbs around the corridor that joins it to the rest of the city
+		{"O'Hare Terminal 2", 41.9786, -87.9048, true},
+		{"O'Hare Multi-Modal Facility", 41.9868, -87.8880, true},
+		{"Rosemont, Allstate Arena", 41.9875, -87.8848, false},
+		{"Schiller Park", 41.9559, -87.8709, false},
+		{"Norwood Park, east of Harlem Avenue", 41.9665, -87.7950, true},
+		{"Harwood Heights, surrounded by the city", 41.9665, -87.8110, false},
+		{"Norridge, surrounded by the city", 41.9630, -87.8250, false},
+		// Elsewhere along the city limits
+		{"West Ridge", 42.0050, -87.6950, true},
+		{"Lincolnwood", 42.0050, -87.7300, false},
+		{"Beverly", 41.7210, -87.6700, true},
+		{"Evergreen Park", 41.7210, -87.7010, false},
+		{"Midway", 41.7868, -87.7522, true},
+		{"Cicero", 41.8520, -87.7530, false},
+		{"Hegewisch", 41.6550, -87.5480, true},
+		{"Hammond, Indiana", 41.6800, -87.5100, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InChicago(tt.lat, tt.lon); got != tt.want {
+				t.Errorf("InChicago(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAirportAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     string
+	}{
+		{"O'Hare Terminal 2 curb", 41.9786, -87.9048, "ORD"},
+		{"O'Hare Terminal 1 concourse C", 41.9845, -87.9080, "ORD"},
+		{"O'Hare Terminal 3 concourse K", 41.9760, -87.9100, "ORD"},
+		{"O'Hare Terminal 5", 41.9737, -87.8907, "ORD"},
+		{"O'Hare Multi-Modal Facility", 41.9868, -87.8880, "ORD"},
+		{"O'Hare apron west of the concourses", 41.9800, -87.9140, ""},
+		{"O'Hare taxiway between Terminals 3 and 5", 41.9740, -87.8970, ""},
+		{"O'Hare west airfield", 41.9900, -87.9350, ""},
+		{"Rosemont, east of Mannheim Road", 41.9780, -87.8630, ""},
+		{"Midway terminal", 41.7868, -87.7435, "MDW"},
+		{"Midway concourse C", 41.7870, -87.7475, "MDW"},
+		{"Midway CTA station", 41.7867, -87.7379, "MDW"},
+		{"Midway garage", 41.7895, -87.7405, "MDW"},
+		{"Midway airfield west of the concourses", 41.7870, -87.7500, ""},
+		{"Midway runway 4R/22L", 41.7860, -87.7520, ""},
+		{"Garfield Ridge homes north of 55th Street", 41.7960, -87.7435, ""},
+		{"West Lawn homes east of the CTA station", 41.7867, -87.7300, ""},
+		{"Garfield Ridge homes north of Midway", 41.8000, -87.7700, ""},
+		{"Loop", 41.8819, -87.6278, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := AirportAt(tt.lat, tt.lon)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("AirportAt(%v, %v) = %q, %v, want %q", tt.lat, tt.lon, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetAirports(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "airports.geojson")
+	data := `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{"code":"MDW"},"geometry":{"type":"Polygon",` +
+		`"coordinates":[[[-87.76,41.78],[-87.74,41.78],[-87.74,41.79],[-87.76,41.79],[-87.76,41.78]]]}}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	geofences, err := ReadAirportsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetAirports(geofences)
+	t.Cleanup(func() { SetAirports(nil) })
+
+	if code, ok := AirportAt(41.7860, -87.7520); code != "MDW" || !ok {
+		t.Errorf("AirportAt() on the runway of the loaded geofence = %q, %v, want MDW", code, ok)
+	}
+	if code, ok := AirportAt(41.9786, -87.9048); ok {
+		t.Errorf("AirportAt() at O'Hare without its geofence = %q, want none", code)
+	}
+	SetAirports(nil)
+	if code, ok := AirportAt(41.9786, -87.9048); code != "ORD" || !ok {
+		t.Errorf("AirportAt() at O'Hare with the bundled geofences = %q, %v, want ORD", code, ok)
+	}
+
+	noCode := filepath.Join(t.TempDir(), "no_code.geojson")
+	if err := os.WriteFile(noCode, []byte(strings.Replace(data, `"code":"MDW"`, `"name":"Midway"`, 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadAirportsFile(noCode); err == nil {
+		t.Error("ReadAirportsFile() of a geofence without a code error = nil, want an error")
+	}
+}
@@ -0,0 +1,72 @@
+package geo
+
+import "math"
+
+// Index finds the feature containing a point without testing every feature:
+// the extent of the features is divided into a grid of square cells, and each
+// cell lists the features whose bounding box overlaps it
+type Index struct {
+	features []*Feature
+	origin   [2]float64 // Minimum longitude and latitude of the grid
+	cellSize float64    // Width and height of a cell in degrees
+	cols     int
+	rows     int
+	cells    [][]int // Indexes of the features overlapping each cell, row by row
+}
+
+// NewIndex indexes features in a grid of cells of cellSize degrees.
+// Cells of about 0.01 degrees, roughly a kilometre, suit city-sized boundaries.
+func NewIndex(features []*Feature, cellSize float64) *Index {
+	ix := &Index{features: features, cellSize: cellSize}
+	if len(features) == 0 || cellSize <= 0 {
+		return ix
+	}
+
+	var polygons [][][][2]float64
+	for _, f := range features {
+		polygons = append(polygons, f.polygons...)
+	}
+	extent := boundingBox(polygons)
+	ix.origin = [2]float64{extent[0], extent[1]}
+	ix.cols = int(math.Floor((extent[2]-extent[0])/cellSize)) + 1
+	ix.rows = int(math.Floor((extent[3]-extent[1])/cellSize)) + 1
+	ix.cells = make([][]int, ix.cols*ix.rows)
+
+	for i, f := range features {
+		col0, row0, _ := ix.cell(f.bbox[1], f.bbox[0])
+		col1, row1, _ := ix.cell(f.bbox[3], f.bbox[2])
+		for row := row0; row <= row1; row++ {
+			for col := col0; col <= col1; col++ {
+				ix.cells[row*ix.cols+col] = append(ix.cells[row*ix.cols+col], i)
+			}
+		}
+	}
+	return ix
+}
+
+// Find returns the first indexed feature containing the point, or nil if none does
+func (ix *Index) Find(lat, lon float64) *Feature {
+	col, row, ok := ix.cell(lat, lon)
+	if !ok {
+		return nil
+	}
+	for _, i := range ix.cells[row*ix.cols+col] {
+		if ix.features[i].Contains(lat, lon) {
+			return ix.features[i]
+		}
+	}
+	return nil
+}
+
+// cell returns the grid cell of a point, clamped to the grid, and whether the point lies within the grid
+func (ix *Index) cell(lat, lon float64) (col, row int, ok bool) {
+	if ix.cols == 0 || math.IsNaN(lat) || math.IsNaN(lon) {
+		return 0, 0, false
+	}
+	x := math.Floor((lon - ix.origin[0]) / ix.cellSize)
+	y := math.Floor((lat - ix.origin[1]) / ix.cellSize)
+	ok = x >= 0 && y >= 0 && x < float64(ix.cols) && y < float64(ix.rows)
+	col = int(min(max(x, 0), float64(ix.cols-1)))
+	row = int(min(max(y, 0), float64(ix.rows-1)))
+	return col, row, ok
+}
@@ -0,0 +1,88 @@
+package geo
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Properties that hold the zip code of a boundary: zip in the City of Chicago's
+// Boundaries - ZIP Codes dataset, ZCTA5CE10 and ZCTA5CE20 in Census Bureau ZCTA files
+var zipProperties = []string{"zip", "zip_code", "ZIP", "ZCTA5CE10", "ZCTA5CE20"}
+
+// Grid cell size of the zip code index in degrees, about a kilometre in Chicago
+const zipCellSize = 0.01
+
+// ZipCodes maps coordinates to the zip code whose boundary contains them
+type ZipCodes struct {
+	index *Index
+	zips  map[*Feature]string // Zip code of each boundary
+	count int
+}
+
+// ReadZipCodes indexes the zip code boundaries of a GeoJSON FeatureCollection.
+// Every feature must carry its zip code in one of the properties zip, zip_code,
+// ZIP, ZCTA5CE10 or ZCTA5CE20. A zip code may have several boundaries.
+func ReadZipCodes(data []byte) (*ZipCodes, error) {
+	features, err := ReadFeatures(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(features) == 0 {
+		return nil, fmt.Errorf("no zip code boundaries found")
+	}
+
+	zips := make(map[*Feature]string, len(features))
+	distinct := make(map[string]bool)
+	for i, f := range features {
+		zip := zipProperty(f.Properties)
+		if zip == "" {
+			return nil, fmt.Errorf("zip code boundary %d has no zip code property", i)
+		}
+		zips[f] = zip
+		distinct[zip] = true
+	}
+	return &ZipCodes{index: NewIndex(features, zipCellSize), zips: zips, count: len(distinct)}, nil
+}
+
+// ReadZipCodesFile indexes the zip code boundaries of a GeoJSON file, see ReadZipCodes
+func ReadZipCodesFile(path string) (*ZipCodes, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip code boundaries: %w", err)
+	}
+	zipCodes, err := ReadZipCodes(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return zipCodes, nil
+}
+
+// Lookup returns the zip code whose boundary contains the point
+func (z *ZipCodes) Lookup(lat, lon float64) (string, bool) {
+	f := z.index.Find(lat, lon)
+	if f == nil {
+		return "", false
+	}
+	return z.zips[f], true
+}
+
+// Len returns the number of distinct zip codes
+func (z *ZipCodes) Len() int {
+	return z.count
+}
+
+// zipProperty returns the zip code held by the properties of a boundary, or "" if none
+func zipProperty(properties map[string]interface{}) string {
+	for _, key := range zipProperties {
+		switch v := properties[key].(type) {
+		case string:
+			if zip := strings.TrimSpace(v); zip != "" {
+				return zip
+			}
+		case float64:
+			return fmt.Sprintf("%05.0f", v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+package geo
+
+import (
+	"fmt"
+	"testing"
+)
+
+// zipFeature returns a GeoJSON feature of a square with the given properties, from lon0, lat0 to lon1, lat1
+func zipFeature(properties string, lon0, lat0, lon1, lat1 float64) string {
+	return fmt.Sprintf(`{"type":"Feature","properties":%s,"geometry":{"type":"Polygon","coordinates":[[[%v,%v],[%v,%v],[%v,%v],[%v,%v],[%v,%v]]]}}`,
+		properties, lon0, lat0, lon1, lat0, lon1, lat1, lon0, lat1, lon0, lat0)
+}
+
+func TestReadZipCodes(t *testing.T) {
+	data := `{"type":"FeatureCollection","features":[` +
+		zipFeature(`{"zip":"60602"}`, -87.64, 41.88, -87.62, 41.89) + "," +
+		zipFeature(`{"ZCTA5CE10":"60606"}`, -87.66, 41.87, -87.64, 41.89) + "," +
+		zipFeature(`{"zip_code":60611}`, -87.62, 41.89, -87.60, 41.90) + "," +
+		zipFeature(`{"zip":"60602"}`, -87.62, 41.88, -87.61, 41.89) + "]}"
+	zipCodes, err := ReadZipCodes([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := zipCodes.Len(); n != 3 {
+		t.Errorf("Len() = %d, want 3 distinct zip codes", n)
+	}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     string
+	}{
+		{"zip property", 41.885, -87.63, "60602"},
+		{"second boundary of a zip code", 41.885, -87.615, "60602"},
+		{"Census Bureau property", 41.88, -87.65, "60606"},
+		{"numeric property", 41.895, -87.61, "60611"},
+		{"outside every boundary", 41.95, -87.65, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := zipCodes.Lookup(tt.lat, tt.lon)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("Lookup(%v, %v) = %q, %v, want %q", tt.lat, tt.lon, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadZipCodesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not GeoJSON", `[1, 2]`},
+		{"no boundaries", `{"type":"FeatureCollection","features":[]}`},
+		{"no zip code property", `{"type":"FeatureCollection","features":[` + zipFeature(`{"name":"Loop"}`, -87.64, 41.88, -87.62, 41.89) + `]}`},
+	}
+	for _, tt := range tests {
+		if _, err := ReadZipCodes([]byte(tt.data)); err == nil {
+			t.Errorf("%s: ReadZipCodes() error = nil, want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+module shared
+
+go 1.23.4
+
+require (
+	github.com/klauspost/compress v1.17.11
+	github.com/linkedin/goavro/v2 v2.15.0
+	github.com/streadway/amqp v1.1.0
+)
+
+require github.com/golang/snappy v0.0.1 // indirect
@@ -0,0 +1,467 @@
+// Package model defines the records of the Chicago datasets processed by the pipeline.
+// The same types are used for the bronze records produced by the cleaner and the
+// silver records produced by the transformer; fields filled in by the transformer,
+// such as zip codes, are empty at the bronze stage. Fields that may be missing from
+// the source data are pointers, which are nil for a missing value and encode as null.
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Table names of the datasets, also used as the prefix of their queue names
+const (
+	TaxiTrips               = "taxi_trips"
+	CovidCases              = "covid_cases"
+	CovidDailyCases         = "covid_daily_cases"
+	CovidVulnerabilityIndex = "covid_vulnerability_index"
+	BuildingPermits         = "building_permits"
+	CensusData              = "census_data"
+	TransportationTrips     = "transportation_trips"
+	PublicHealthStatistics  = "public_health_statistics"
+	Crimes                  = "crimes"
+	CTARidership            = "cta_ridership"
+	BusinessLicenses        = "business_licenses"
+)
+
+// Datasets lists every dataset processed by the pipeline
+var Datasets = []string{
+	TaxiTrips,
+	CovidCases,
+	CovidDailyCases,
+	CovidVulnerabilityIndex,
+	BuildingPermits,
+	CensusData,
+	TransportationTrips,
+	PublicHealthStatistics,
+	Crimes,
+	CTARidership,
+	BusinessLicenses,
+}
+
+// Queue of the per-batch drop summaries published by the cleaner, also the name of their table
+const DropSummaries = "drop_summary"
+
+// Queue of the per-batch data quality metrics published by the cleaner, also the name of their table
+const QualityMetrics = "data_quality_metrics"
+
+// Queues returns the queue name of every dataset at a pipeline stage, e.g. taxi_trips_raw
+func Queues(stage string) []string {
+	queues := make([]string, len(Datasets))
+	for i, dataset := range Datasets {
+		queues[i] = dataset + "_" + stage
+	}
+	return queues
+}
+
+// TaxiTrip is a trip from the Taxi Trips dataset
+type TaxiTrip struct {
+	Trip_id                     string    `json:"trip_id"`
+	Trip_start_timestamp        time.Time `json:"trip_start_timestamp"`
+	Trip_end_timestamp          time.Time `json:"trip_end_timestamp"`
+	Trip_duration_seconds       *int64    `json:"trip_duration_seconds"` // Computed by the cleaner
+	Pickup_centroid_latitude    *float64  `json:"pickup_centroid_latitude"`
+	Pickup_centroid_longitude   *float64  `json:"pickup_centroid_longitude"`
+	Pickup_community_area       *int64    `json:"pickup_community_area"`
+	Pickup_community_area_name  *string   `json:"pickup_community_area_name"` // Canonical name, set by the cleaner
+	Dropoff_centroid_latitude   *float64  `json:"dropoff_centroid_latitude"`
+	Dropoff_centroid_longitude  *float64  `json:"dropoff_centroid_longitude"`
+	Dropoff_community_area      *int64    `json:"dropoff_community_area"`
+	Dropoff_community_area_name *string   `json:"dropoff_community_area_name"`
+	Pickup_zipcode              *string   `json:"pickup_zipcode"`
+	Dropoff_zipcode             *string   `json:"dropoff_zipcode"`
+	Pickup_airport              *string   `json:"pickup_airport"` // ORD or MDW, set by the transformer
+	Dropoff_airport             *string   `json:"dropoff_airport"`
+}
+
+// Validate checks that the trip can be identified and placed in time
+func (t TaxiTrip) Validate() error {
+	return firstError(
+		required("trip_id", t.Trip_id),
+		requiredTime("trip_start_timestamp", t.Trip_start_timestamp),
+		requiredTime("trip_end_timestamp", t.Trip_end_timestamp),
+	)
+}
+
+// TransportationTrip is a trip from the Transportation Network Providers Trips dataset
+type TransportationTrip struct {
+	Trip_id                     string    `json:"trip_id"`
+	Trip_start_timestamp        time.Time `json:"trip_start_timestamp"`
+	Trip_end_timestamp          time.Time `json:"trip_end_timestamp"`
+	Trip_duration_seconds       *int64    `json:"trip_duration_seconds"` // Computed by the cleaner
+	Pickup_census_tract         *string   `json:"pickup_census_tract"`
+	Dropoff_census_tract        *string   `json:"dropoff_census_tract"`
+	Pickup_community_area       *int64    `json:"pickup_community_area"`
+	Pickup_community_area_name  *string   `json:"pickup_community_area_name"` // Canonical name, set by the cleaner
+	Dropoff_community_area      *int64    `json:"dropoff_community_area"`
+	Dropoff_community_area_name *string   `json:"dropoff_community_area_name"`
+	Pickup_centroid_latitude    *float64  `json:"pickup_centroid_latitude"`
+	Pickup_centroid_longitude   *float64  `json:"pickup_centroid_longitude"`
+	Dropoff_centroid_latitude   *float64  `json:"dropoff_centroid_latitude"`
+	Dropoff_centroid_longitude  *float64  `json:"dropoff_centroid_longitude"`
+	Pickup_zipcode              *string   `json:"pickup_zipcode"`
+	Dropoff_zipcode             *string   `json:"dropoff_zipcode"`
+	Pickup_airport              *string   `json:"pickup_airport"` // ORD or MDW, set by the transformer
+	Dropoff_airport             *string   `json:"dropoff_airport"`
+}
+
+// Validate checks that the trip can be identified and placed in time
+func (t TransportationTrip) Validate() error {
+	return firstError(
+		required("trip_id", t.Trip_id),
+		requiredTime("trip_start_timestamp", t.Trip_start_timestamp),
+		requiredTime("trip_end_timestamp", t.Trip_end_timestamp),
+	)
+}
+
+// CovidWeeklyCase is one week of COVID-19 cases, tests and deaths for a zip code
+type CovidWeeklyCase struct {
+	Zip_code                           string    `json:"zip_code"`
+	Week_number                        *string   `json:"week_number"`
+	Week_start                         time.Time `json:"week_start"`
+	Week_end                           time.Time `json:"week_end"`
+	Cases_weekly                       *int64    `json:"cases_weekly"`
+	Cases_cumulative                   *int64    `json:"cases_cumulative"`
+	Case_rate_weekly                   *float64  `json:"case_rate_weekly"`
+	Case_rate_cumulative               *float64  `json:"case_rate_cumulative"`
+	Tests_weekly                       *int64    `json:"tests_weekly"`
+	Tests_cumulative                   *int64    `json:"tests_cumulative"`
+	Test_rate_weekly                   *float64  `json:"test_rate_weekly"`
+	Test_rate_cumulative               *float64  `json:"test_rate_cumulative"`
+	Percent_tested_positive_weekly     *float64  `json:"percent_tested_positive_weekly"`
+	Percent_tested_positive_cumulative *float64  `json:"percent_tested_positive_cumulative"`
+	Deaths_weekly                      *int64    `json:"deaths_weekly"`
+	Deaths_cumulative                  *int64    `json:"deaths_cumulative"`
+	Death_rate_weekly                  *float64  `json:"death_rate_weekly"`
+	Death_rate_cumulative              *float64  `json:"death_rate_cumulative"`
+	Population                         *int64    `json:"population"`
+	Row_id                             string    `json:"row_id"`
+	Latitude                           *float64  `json:"latitude"`
+	Longitude                          *float64  `json:"longitude"`
+	Previous_case_rate_weekly          *float64  `json:"previous_case_rate_weekly"` // Case rate of the week before, set by the transformer
+	Case_rate_trend                    *float64  `json:"case_rate_trend"`           // Relative change from the week before
+	Severity                           *string   `json:"severity"`                  // Low, Medium or High
+}
+
+// Validate checks that the week can be tied to a zip code and a date range
+func (c CovidWeeklyCase) Validate() error {
+	return firstError(
+		required("zip_code", c.Zip_code),
+		required("row_id", c.Row_id),
+		requiredTime("week_start", c.Week_start),
+		requiredTime("week_end", c.Week_end),
+	)
+}
+
+// CovidDailyCase is one day of citywide COVID-19 cases, deaths and hospitalizations,
+// counted by the date of the laboratory report
+type CovidDailyCase struct {
+	Lab_report_date        time.Time `json:"lab_report_date"`
+	Cases_total            *int64    `json:"cases_total"`
+	Deaths_total           *int64    `json:"deaths_total"`
+	Hospitalizations_total *int64    `json:"hospitalizations_total"`
+}
+
+// Validate checks that the counts can be tied to a day
+func (c CovidDailyCase) Validate() error {
+	return requiredTime("lab_report_date", c.Lab_report_date)
+}
+
+// CCVIEntry is the COVID-19 Community Vulnerability Index of a community area or zip code.
+// The cleaner splits Community_area_or_zip by Geography_type, CA or ZIP, into
+// Community_area_number or Zip_code; the ranks are the components of the score.
+type CCVIEntry struct {
+	Geography_type                        *string  `json:"geography_type"`
+	Community_area_or_zip                 string   `json:"community_area_or_zip"`
+	Community_area_number                 *int64   `json:"community_area_number"`
+	Community_area_name                   *string  `json:"community_area_name"`
+	Zip_code                              *string  `json:"zip_code"`
+	CCVI_score                            *float64 `json:"ccvi_score"`
+	CCVI_category                         string   `json:"ccvi_category"`
+	Rank_socioeconomic_status             *int64   `json:"rank_socioeconomic_status"`
+	Rank_household_composition            *int64   `json:"rank_household_composition"`
+	Rank_adults_no_pcp                    *int64   `json:"rank_adults_no_pcp"`
+	Rank_cumulative_mobility_ratio        *int64   `json:"rank_cumulative_mobility_ratio"`
+	Rank_frontline_essential_workers      *int64   `json:"rank_frontline_essential_workers"`
+	Rank_age_65_plus                      *int64   `json:"rank_age_65_plus"`
+	Rank_comorbid_conditions              *int64   `json:"rank_comorbid_conditions"`
+	Rank_covid_19_incidence_rate          *int64   `json:"rank_covid_19_incidence_rate"`
+	Rank_covid_19_hospital_admission_rate *int64   `json:"rank_covid_19_hospital_admission_rate"`
+	Rank_covid_19_crude_mortality_rate    *int64   `json:"rank_covid_19_crude_mortality_rate"`
+}
+
+// Validate checks that the entry has a geography and a category
+func (e CCVIEntry) Validate() error {
+	if err := firstError(
+		required("community_area_or_zip", e.Community_area_or_zip),
+		required("ccvi_category", e.CCVI_category),
+	); err != nil {
+		return err
+	}
+	if e.Community_area_number == nil && e.Zip_code == nil {
+		return fmt.Errorf("both community_area_number and zip_code are missing")
+	}
+	return nil
+}
+
+// BuildingPermit is a permit from the Building Permits dataset
+type BuildingPermit struct {
+	Id                     string    `json:"id"`
+	Permit_status          *string   `json:"permit_status"`
+	Permit_type            string    `json:"permit_type"`
+	Review_type            *string   `json:"review_type"`
+	Application_start_date time.Time `json:"application_start_date"`
+	Issue_date             time.Time `json:"issue_date"`
+	Street_number          *string   `json:"street_number"`
+	Street_direction       *string   `json:"street_direction"`
+	Street_name            *string   `json:"street_name"`
+	Work_type              *string   `json:"work_type"`
+	Total_fee              *float64  `json:"total_fee"`
+	Building_fee_paid      *float64  `json:"building_fee_paid"`
+	Zoning_fee_paid        *float64  `json:"zoning_fee_paid"`
+	Other_fee_paid         *float64  `json:"other_fee_paid"`
+	Subtotal_paid          *float64  `json:"subtotal_paid"`
+	Building_fee_unpaid    *float64  `json:"building_fee_unpaid"`
+	Zoning_fee_unpaid      *float64  `json:"zoning_fee_unpaid"`
+	Other_fee_unpaid       *float64  `json:"other_fee_unpaid"`
+	Subtotal_unpaid        *float64  `json:"subtotal_unpaid"`
+	Building_fee_waived    *float64  `json:"building_fee_waived"`
+	Zoning_fee_waived      *float64  `json:"zoning_fee_waived"`
+	Other_fee_waived       *float64  `json:"other_fee_waived"`
+	Subtotal_waived        *float64  `json:"subtotal_waived"`
+	Building_fee_subtotal  *float64  `json:"building_fee_subtotal"`
+	Zoning_fee_subtotal    *float64  `json:"zoning_fee_subtotal"`
+	Other_fee_subtotal     *float64  `json:"other_fee_subtotal"`
+	Reported_cost          *float64  `json:"reported_cost"` // Estimated cost of the work in dollars
+	Community_area         *int64    `json:"community_area"`
+	Community_area_name    *string   `json:"community_area_name"` // Canonical name, set by the cleaner
+	Latitude               *float64  `json:"latitude"`
+	Longitude              *float64  `json:"longitude"`
+	Zipcode                *string   `json:"zipcode"`
+}
+
+// Validate checks that the permit can be identified and located
+func (p BuildingPermit) Validate() error {
+	if err := firstError(
+		required("id", p.Id),
+		required("permit_type", p.Permit_type),
+		requiredTime("application_start_date", p.Application_start_date),
+		requiredTime("issue_date", p.Issue_date),
+	); err != nil {
+		return err
+	}
+	if p.Community_area == nil && p.Latitude == nil && p.Longitude == nil {
+		return fmt.Errorf("all location fields are missing")
+	}
+	return nil
+}
+
+// CensusArea holds the socioeconomic indicators of a community area from the Census Data dataset
+type CensusArea struct {
+	Community_area_number            int64    `json:"community_area_number"`
+	Community_area_name              string   `json:"community_area_name"`
+	Percent_households_below_poverty *float64 `json:"percent_households_below_poverty"`
+	Percent_aged_16_unemployed       *float64 `json:"percent_aged_16_unemployed"`
+	Per_capita_income                *int64   `json:"per_capita_income"`
+}
+
+// Validate checks that the indicators belong to a community area
+func (c CensusArea) Validate() error {
+	return firstError(
+		requiredInt("community_area_number", c.Community_area_number),
+		required("community_area_name", c.Community_area_name),
+	)
+}
+
+// PublicHealthStat holds the public health indicators of a community area
+type PublicHealthStat struct {
+	Community_area      int64    `json:"community_area"`
+	Community_area_name string   `json:"community_area_name"`
+	Below_poverty_level *float64 `json:"below_poverty_level"`
+	Per_capita_income   *float64 `json:"per_capita_income"`
+	Unemployment        *float64 `json:"unemployment"`
+}
+
+// Validate checks that the indicators belong to a community area
+func (s PublicHealthStat) Validate() error {
+	return firstError(
+		requiredInt("community_area", s.Community_area),
+		required("community_area_name", s.Community_area_name),
+	)
+}
+
+// Crime is a reported incident from the Crimes - 2001 to Present dataset
+type Crime struct {
+	Id                   string     `json:"id"`
+	Case_number          *string    `json:"case_number"`
+	Date                 time.Time  `json:"date"`
+	Block                *string    `json:"block"`
+	Iucr                 *string    `json:"iucr"`
+	Primary_type         string     `json:"primary_type"`
+	Description          *string    `json:"description"`
+	Location_description *string    `json:"location_description"`
+	Arrest               *bool      `json:"arrest"`
+	Domestic             *bool      `json:"domestic"`
+	Beat                 *string    `json:"beat"`
+	District             *string    `json:"district"`
+	Ward                 *int64     `json:"ward"`
+	Community_area       *int64     `json:"community_area"`
+	Community_area_name  *string    `json:"community_area_name"` // Canonical name, set by the cleaner
+	Fbi_code             *string    `json:"fbi_code"`
+	Year                 *int64     `json:"year"`
+	Updated_on           *time.Time `json:"updated_on"`
+	Latitude             *float64   `json:"latitude"`
+	Longitude            *float64   `json:"longitude"`
+}
+
+// Validate checks that the crime can be identified, placed in time and classified
+func (c Crime) Validate() error {
+	return firstError(
+		required("id", c.Id),
+		requiredTime("date", c.Date),
+		required("primary_type", c.Primary_type),
+	)
+}
+
+// StationRidership is the number of entries at a CTA 'L' station on one day
+type StationRidership struct {
+	Station_id   string    `json:"station_id"`
+	Station_name string    `json:"station_name"`
+	Date         time.Time `json:"date"`
+	Day_type     *string   `json:"day_type"` // W for weekdays, A for Saturdays, U for Sundays and holidays
+	Rides        int64     `json:"rides"`
+}
+
+// Validate checks that the ridership belongs to a station and a day
+func (r StationRidership) Validate() error {
+	return firstError(
+		required("station_id", r.Station_id),
+		required("station_name", r.Station_name),
+		requiredTime("date", r.Date),
+	)
+}
+
+// BusinessLicense is a license from the Business Licenses - Current Active dataset
+type BusinessLicense struct {
+	Id                     string     `json:"id"`
+	License_id             *string    `json:"license_id"`
+	Account_number         *string    `json:"account_number"`
+	Legal_name             *string    `json:"legal_name"`
+	Doing_business_as_name *string    `json:"doing_business_as_name"`
+	Address                *string    `json:"address"`
+	City                   *string    `json:"city"`
+	State                  *string    `json:"state"`
+	Zip_code               *string    `json:"zip_code"`
+	Ward                   *int64     `json:"ward"`
+	Precinct               *int64     `json:"precinct"`
+	Police_district        *string    `json:"police_district"`
+	Community_area         *int64     `json:"community_area"`
+	Community_area_name    *string    `json:"community_area_name"` // Canonical name, set by the cleaner
+	License_code           *string    `json:"license_code"`
+	License_description    *string    `json:"license_description"`
+	Business_activity      *string    `json:"business_activity"`
+	License_number         *string    `json:"license_number"`
+	Application_type       *string    `json:"application_type"`
+	License_status         *string    `json:"license_status"`
+	License_start_date     *time.Time `json:"license_start_date"`
+	Expiration_date        *time.Time `json:"expiration_date"`
+	Date_issued            *time.Time `json:"date_issued"`
+	Latitude               *float64   `json:"latitude"`
+	Longitude              *float64   `json:"longitude"`
+}
+
+// Validate checks that the license can be identified
+func (l BusinessLicense) Validate() error {
+	return required("id", l.Id)
+}
+
+// Ptr returns a pointer to v, for setting nullable fields
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Value returns the value p points to, or the zero value of T if p is nil
+func Value[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
+// QuarantinedRecord is a raw row rejected by the cleaner, published to <dataset>_quarantine
+type QuarantinedRecord struct {
+	Batch_id    string    `json:"batch_id"`
+	Dataset     string    `json:"dataset"`
+	Row_index   int64     `json:"row_index"`  // Index of the row in the raw page
+	Field_name  *string   `json:"field_name"` // Field that failed, nil for record rules
+	Rule        string    `json:"rule"`
+	Reason      string    `json:"reason"`
+	Raw_record  string    `json:"raw_record"` // The row as received, as JSON
+	Rejected_at time.Time `json:"rejected_at"`
+}
+
+// DropSummary counts the rows of a cleaned batch dropped for one reason.
+// A batch without drops has a single summary with no field or rule and no dropped records.
+type DropSummary struct {
+	Batch_id         string    `json:"batch_id"`
+	Dataset          string    `json:"dataset"`
+	Cleaned_at       time.Time `json:"cleaned_at"`
+	Received_records int64     `json:"received_records"` // Rows in the raw page
+	Cleaned_records  int64     `json:"cleaned_records"`  // Rows published to the bronze queue
+	Field_name       *string   `json:"field_name"`
+	Rule             *string   `json:"rule"`
+	Dropped_records  int64     `json:"dropped_records"` // Rows dropped for this field and rule
+}
+
+// QualityMetric is one data quality measurement of a cleaned batch: the null_rate,
+// parse_failure_rate or lossy_rate of a field, the duplicate_rate of the key fields, or the value_share
+// of a category of a categorical field. Value is Count divided by Total.
+type QualityMetric struct {
+	Batch_id         string    `json:"batch_id"`
+	Dataset          string    `json:"dataset"`
+	Measured_at      time.Time `json:"measured_at"`
+	Field_name       *string   `json:"field_name"` // Comma-separated for the key fields of duplicate_rate
+	Metric           string    `json:"metric"`
+	Category         *string   `json:"category"` // Category of a value_share
+	Value            float64   `json:"value"`
+	Count            int64     `json:"count"`
+	Total            int64     `json:"total"`
+	Trailing_average *float64  `json:"trailing_average"` // Average of the metric over the previous batches, nil for the first
+	Alert            bool      `json:"alert"`            // The value moved away from the trailing average by more than the threshold
+}
+
+// required reports a missing string field
+func required(field, value string) error {
+	if value == "" {
+		return fmt.Errorf("missing %s", field)
+	}
+	return nil
+}
+
+// requiredInt reports a missing number field, such as a community area number, which starts at 1
+func requiredInt(field string, value int64) error {
+	if value == 0 {
+		return fmt.Errorf("missing %s", field)
+	}
+	return nil
+}
+
+// requiredTime reports a missing timestamp field
+func requiredTime(field string, value time.Time) error {
+	if value.IsZero() {
+		return fmt.Errorf("missing %s", field)
+	}
+	return nil
+}
+
+// firstError returns the first non-nil error
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+// Package postgres builds the connection settings of the Postgres database of the pipeline
+// from the POSTGRES_* variables shared by the services.
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"shared/env"
+)
+
+// Host of the database in the compose network when POSTGRES_HOST is not set
+const defaultHost = "postgres"
+
+// Port of the database when POSTGRES_PORT is not set
+const defaultPort = 5432
+
+// Configured reports whether POSTGRES_DB names a database to connect to
+func Configured() bool {
+	return os.Getenv("POSTGRES_DB") != ""
+}
+
+// ConnString returns the lib/pq connection string of the database named by POSTGRES_DB,
+// at POSTGRES_HOST and POSTGRES_PORT, as POSTGRES_USER with POSTGRES_PASSWORD
+func ConnString() string {
+	host := os.Getenv("POSTGRES_HOST")
+	if host == "" {
+		host = defaultHost
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		quote(host), env.Int("POSTGRES_PORT", defaultPort), quote(os.Getenv("POSTGRES_USER")),
+		quote(os.Getenv("POSTGRES_PASSWORD")), quote(os.Getenv("POSTGRES_DB")))
+}
+
+// quote quotes a connection string value, so that values holding spaces or quotes stay whole
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
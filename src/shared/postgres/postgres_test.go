@@ -0,0 +1,41 @@
+package postgres
+
+import "testing"
+
+func TestConnString(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"defaults", map[string]string{"POSTGRES_USER": "pipeline", "POSTGRES_PASSWORD": "secret", "POSTGRES_DB": "chicago"},
+			`host='postgres' port=5432 user='pipeline' password='secret' dbname='chicago' sslmode=disable`},
+		{"host and port", map[string]string{"POSTGRES_HOST": "db.internal", "POSTGRES_PORT": "6543", "POSTGRES_DB": "chicago"},
+			`host='db.internal' port=6543 user='' password='' dbname='chicago' sslmode=disable`},
+		{"invalid port", map[string]string{"POSTGRES_PORT": "five", "POSTGRES_DB": "chicago"},
+			`host='postgres' port=5432 user='' password='' dbname='chicago' sslmode=disable`},
+		{"quotes and spaces", map[string]string{"POSTGRES_PASSWORD": `it's a \secret`, "POSTGRES_DB": "chicago"},
+			`host='postgres' port=5432 user='' password='it\'s a \\secret' dbname='chicago' sslmode=disable`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"POSTGRES_HOST", "POSTGRES_PORT", "POSTGRES_USER", "POSTGRES_PASSWORD", "POSTGRES_DB"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if got := ConnString(); got != tt.want {
+				t.Errorf("ConnString() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigured(t *testing.T) {
+	t.Setenv("POSTGRES_DB", "")
+	if Configured() {
+		t.Error("Configured() = true without POSTGRES_DB")
+	}
+	t.Setenv("POSTGRES_DB", "chicago")
+	if !Configured() {
+		t.Error("Configured() = false with POSTGRES_DB")
+	}
+}
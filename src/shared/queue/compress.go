@@ -0,0 +1,116 @@
+// Package queue holds the RabbitMQ consumer and publisher plumbing shared by the services:
+// compression of message bodies and the worker pool that acknowledges deliveries in order.
+package queue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"shared/env"
+)
+
+// Messages smaller than this are published uncompressed unless QUEUE_COMPRESSION_THRESHOLD is set
+const defaultCompressionThreshold = 64 * 1024
+
+// Running totals of message sizes before and after compression
+var bytesBeforeCompression atomic.Int64
+var bytesAfterCompression atomic.Int64
+
+// compressionAlgorithm returns the content encoding set with QUEUE_COMPRESSION: gzip, zstd or none
+func compressionAlgorithm() string {
+	algorithm := strings.ToLower(os.Getenv("QUEUE_COMPRESSION"))
+	switch algorithm {
+	case "", "none":
+		return ""
+	case "gzip", "zstd":
+		return algorithm
+	default:
+		log.Printf("Unknown QUEUE_COMPRESSION %q, publishing uncompressed", algorithm)
+		return ""
+	}
+}
+
+// compressionThreshold returns the minimum message size in bytes that is compressed
+func compressionThreshold() int {
+	return env.Int("QUEUE_COMPRESSION_THRESHOLD", defaultCompressionThreshold)
+}
+
+// Compress compresses a message body when compression is enabled and the body is large enough.
+// It returns the body to publish and its content encoding, which is empty for uncompressed bodies.
+func Compress(queueName string, body []byte) ([]byte, string) {
+	algorithm := compressionAlgorithm()
+	if algorithm == "" || len(body) < compressionThreshold() {
+		return body, ""
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch algorithm {
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		if _, err = w.Write(body); err == nil {
+			err = w.Close()
+		}
+	case "zstd":
+		var w *zstd.Encoder
+		w, err = zstd.NewWriter(&buf)
+		if err == nil {
+			if _, err = w.Write(body); err == nil {
+				err = w.Close()
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to %s-compress message for %s, publishing uncompressed: %v", algorithm, queueName, err)
+		return body, ""
+	}
+
+	// Keep the original body if compression does not help
+	if buf.Len() >= len(body) {
+		return body, ""
+	}
+
+	before := bytesBeforeCompression.Add(int64(len(body)))
+	after := bytesAfterCompression.Add(int64(buf.Len()))
+	log.Printf("Compressed message for %s with %s: %d -> %d bytes (%.1f%% saved, %d bytes saved in total)",
+		queueName, algorithm, len(body), buf.Len(), 100*(1-float64(buf.Len())/float64(len(body))), before-after)
+	return buf.Bytes(), algorithm
+}
+
+// Decompress returns the uncompressed body of a message based on its content encoding
+func Decompress(contentEncoding string, body []byte) ([]byte, error) {
+	var r io.Reader
+	switch contentEncoding {
+	case "", "identity":
+		return body, nil
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip body: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zstd body: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", contentEncoding)
+	}
+
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s body: %w", contentEncoding, err)
+	}
+	return decompressed, nil
+}
@@ -0,0 +1,93 @@
+package queue
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	large := []byte(`[` + strings.Repeat(`{"trip_id":"0a1b2c","trip_miles":"3.2","pickup_community_area":"8"},`, 2000) + `{}]`)
+
+	tests := []struct {
+		name         string
+		compression  string
+		body         []byte
+		wantEncoding string
+	}{
+		{"gzip", "gzip", large, "gzip"},
+		{"zstd", "zstd", large, "zstd"},
+		{"upper case", "ZSTD", large, "zstd"},
+		{"none", "none", large, ""},
+		{"unset", "", large, ""},
+		{"unknown", "brotli", large, ""},
+		{"below threshold", "gzip", []byte(`[{"trip_id":"0a1b2c"}]`), ""},
+		{"empty gzip", "gzip", []byte{}, ""},
+		{"empty zstd", "zstd", []byte{}, ""},
+		{"incompressible", "gzip", incompressible(2048), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("QUEUE_COMPRESSION", tt.compression)
+			t.Setenv("QUEUE_COMPRESSION_THRESHOLD", "1024")
+
+			compressed, encoding := Compress("taxi_trips_raw", tt.body)
+			if encoding != tt.wantEncoding {
+				t.Fatalf("Compress() encoding = %q, want %q", encoding, tt.wantEncoding)
+			}
+			if encoding != "" && len(compressed) >= len(tt.body) {
+				t.Errorf("Compress() = %d bytes, want fewer than %d", len(compressed), len(tt.body))
+			}
+
+			body, err := Decompress(encoding, compressed)
+			if err != nil {
+				t.Fatalf("Decompress(%q) error = %v", encoding, err)
+			}
+			if !bytes.Equal(body, tt.body) {
+				t.Errorf("Decompress(%q) = %d bytes, want the %d bytes compressed", encoding, len(body), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     []byte
+		wantErr  bool
+	}{
+		{"identity", "identity", []byte("[]"), []byte("[]"), false},
+		{"empty identity", "", []byte{}, []byte{}, false},
+		{"unknown", "br", []byte("[]"), nil, true},
+		{"empty gzip", "gzip", []byte{}, nil, true},
+		{"empty zstd", "zstd", []byte{}, []byte{}, false},
+		{"not gzip", "gzip", []byte("[]"), nil, true},
+		{"not zstd", "zstd", []byte("[]"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := Decompress(tt.encoding, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decompress(%q) error = %v, want error %v", tt.encoding, err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(body, tt.want) {
+				t.Errorf("Decompress(%q) = %q, want %q", tt.encoding, body, tt.want)
+			}
+		})
+	}
+}
+
+// incompressible returns n pseudo-random bytes, which no algorithm makes smaller
+func incompressible(n int) []byte {
+	b := make([]byte, n)
+	x := uint32(2463534242)
+	for i := range b {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		b[i] = byte(x)
+	}
+	return b
+}
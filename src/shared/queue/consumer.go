@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrDeliveriesClosed reports that the broker ended the deliveries of a consumer that was not cancelled,
+// such as after a broker restart or a channel closed by the server
+var ErrDeliveriesClosed = errors.New("deliveries closed by the broker")
+
+// ConsumerStopped returns the error of a consumer whose deliveries ended: nil if ctx was cancelled,
+// so the consumer stopped on purpose, and ErrDeliveriesClosed otherwise, so that it is restarted
+func ConsumerStopped(ctx context.Context, queueName string) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return fmt.Errorf("consumer of queue %s stopped: %w", queueName, ErrDeliveriesClosed)
+}
+
+// KeepConsuming runs consume until ctx is cancelled, restarting it after delay whenever it returns,
+// such as when its queue does not exist yet or its connection was lost
+func KeepConsuming(ctx context.Context, queueName string, delay time.Duration, consume func(context.Context) error) {
+	for {
+		err := consume(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = ConsumerStopped(ctx, queueName)
+		}
+		log.Printf("Restarting the consumer of queue %s in %s: %v", queueName, delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// WaitForShutdown waits up to timeout for the consumers of wg to drain and returns the process exit code
+func WaitForShutdown(wg *sync.WaitGroup, timeout time.Duration) int {
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		log.Printf("All consumers stopped, exiting")
+		return 0
+	case <-time.After(timeout):
+		log.Printf("Consumers did not stop within %s, exiting", timeout)
+		return 1
+	}
+}
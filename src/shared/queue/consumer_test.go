@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConsumerStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := ConsumerStopped(ctx, "taxi_trips_raw"); !errors.Is(err, ErrDeliveriesClosed) {
+		t.Errorf("ConsumerStopped() before cancel = %v, want ErrDeliveriesClosed", err)
+	}
+	cancel()
+	if err := ConsumerStopped(ctx, "taxi_trips_raw"); err != nil {
+		t.Errorf("ConsumerStopped() after cancel = %v, want nil", err)
+	}
+}
+
+func TestKeepConsumingRestarts(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"deliveries closed", ErrDeliveriesClosed},
+		{"missing queue", errors.New("NOT_FOUND - no queue 'taxi_trips_raw'")},
+		{"returned without an error", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			calls := 0
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				KeepConsuming(ctx, "taxi_trips_raw", time.Millisecond, func(context.Context) error {
+					calls++
+					if calls == 3 {
+						cancel()
+						return nil
+					}
+					return tt.err
+				})
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("KeepConsuming() did not return after cancel")
+			}
+			if calls != 3 {
+				t.Errorf("consume called %d times, want 3", calls)
+			}
+		})
+	}
+}
+
+func TestKeepConsumingStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	// A long delay must not hold up the shutdown
+	KeepConsuming(ctx, "taxi_trips_raw", time.Hour, func(context.Context) error {
+		calls++
+		return ErrDeliveriesClosed
+	})
+	if calls != 1 {
+		t.Errorf("consume called %d times, want 1", calls)
+	}
+}
+
+func TestWaitForShutdown(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		wg.Done()
+	}()
+	if code := WaitForShutdown(&wg, 5*time.Second); code != 0 {
+		t.Errorf("WaitForShutdown() of drained consumers = %d, want 0", code)
+	}
+
+	var stuck sync.WaitGroup
+	stuck.Add(1)
+	defer stuck.Done()
+	if code := WaitForShutdown(&stuck, 10*time.Millisecond); code != 1 {
+		t.Errorf("WaitForShutdown() past the timeout = %d, want 1", code)
+	}
+}
@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Suffix of the queue that receives the messages which crashed their consumer, e.g. taxi_trips_raw_dead_letter
+const DeadLetterSuffix = "_dead_letter"
+
+// PanicError reports a panic recovered while processing a message
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// ProcessSafely runs processFunc and turns a panic into a PanicError,
+// so a single malformed message cannot stop the service
+func ProcessSafely(processFunc ProcessFunc, body []byte, queueName string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return processFunc(body, queueName)
+}
+
+// DeadLetter returns the message to publish to the dead-letter queue of queueName for a message
+// that crashed its consumer. The message is kept as received, with the panic recorded in its
+// headers, so it can be inspected and replayed once the cause is fixed.
+func DeadLetter(queueName string, msg amqp.Delivery, cause *PanicError) amqp.Publishing {
+	return amqp.Publishing{
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		Body:            msg.Body,
+		Headers: amqp.Table{
+			"x-original-queue": queueName,
+			"x-error":          cause.Error(),
+			"x-stack":          string(cause.Stack),
+			"x-failed-at":      time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+}
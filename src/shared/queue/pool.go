@@ -0,0 +1,134 @@
+package queue
+
+import (
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/streadway/amqp"
+	"shared/env"
+)
+
+// Acknowledger acknowledges deliveries of a channel, such as *amqp.Channel
+type Acknowledger interface {
+	Ack(tag uint64, multiple bool) error
+}
+
+// ProcessFunc processes the decompressed body of a message received from a queue
+type ProcessFunc func(body []byte, queueName string) error
+
+// DeadLetterFunc moves a message whose processing panicked out of its queue
+type DeadLetterFunc func(queueName string, msg amqp.Delivery, cause *PanicError) error
+
+// result reports that a delivery finished processing
+type result struct {
+	tag uint64
+	err error
+}
+
+// WorkerCount returns the number of workers per queue, set with CONSUMER_WORKERS
+func WorkerCount(def int) int {
+	return env.Int("CONSUMER_WORKERS", def)
+}
+
+// PrefetchCount returns the maximum number of in-flight messages per queue, set with CONSUMER_PREFETCH
+func PrefetchCount(workers, def int) int {
+	prefetch := env.Int("CONSUMER_PREFETCH", def)
+	if prefetch < workers {
+		return workers // Keep every worker busy
+	}
+	return prefetch
+}
+
+// RunWorkers processes deliveries with a pool of workers and acknowledges them in delivery order.
+// It returns once msgs is closed and every in-flight message has been processed and acknowledged.
+// With a deadLetter function, a panic while processing a message is recovered and the message
+// handed to it; without one, the panic stops the service.
+func RunWorkers(ack Acknowledger, msgs <-chan amqp.Delivery, queueName string, workers int, processFunc ProcessFunc, deadLetter DeadLetterFunc) {
+	jobs := make(chan amqp.Delivery)
+	results := make(chan result)
+
+	// Start the workers
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				log.Printf("Received message from source: %s", queueName)
+				body, err := Decompress(msg.ContentEncoding, msg.Body)
+				if err == nil {
+					if deadLetter != nil {
+						err = ProcessSafely(processFunc, body, queueName)
+					} else {
+						err = processFunc(body, queueName)
+					}
+				}
+				if err != nil {
+					log.Printf("Error processing message: %v", err)
+				}
+
+				// Keep messages that crashed the processing for inspection
+				var pe *PanicError
+				if errors.As(err, &pe) {
+					log.Printf("Recovered from panic processing message from %s:\n%s", queueName, pe.Stack)
+					if err := deadLetter(queueName, msg, pe); err != nil {
+						log.Printf("Failed to move message to the dead-letter queue: %v", err)
+					}
+				}
+				results <- result{tag: msg.DeliveryTag, err: err}
+			}
+		}()
+	}
+
+	// Acknowledge finished deliveries
+	acked := make(chan struct{})
+	go func() {
+		defer close(acked)
+		ackInOrder(ack, results)
+	}()
+
+	// Hand out deliveries until the channel is closed
+	for msg := range msgs {
+		jobs <- msg
+	}
+
+	// Drain: let the workers finish, then flush the remaining acknowledgements
+	close(jobs)
+	wg.Wait()
+	close(results)
+	<-acked
+}
+
+// ackInOrder acknowledges deliveries only once every earlier delivery on the channel has finished,
+// so a crash never acknowledges a message that was overtaken by a faster worker.
+// Failed messages are acknowledged as well; they are logged by the worker and not redelivered,
+// and those that panicked are moved to the dead-letter queue first.
+func ackInOrder(ack Acknowledger, results <-chan result) {
+	var next uint64 = 1 // Delivery tags start at 1 on every channel
+	done := make(map[uint64]bool)
+
+	for res := range results {
+		done[res.tag] = true
+
+		// Advance over the contiguous run of finished deliveries
+		last := uint64(0)
+		for done[next] {
+			delete(done, next)
+			last = next
+			next++
+		}
+		if last == 0 {
+			continue
+		}
+
+		// Acknowledge everything up to and including last
+		if err := ack.Ack(last, true); err != nil {
+			log.Printf("Failed to acknowledge messages up to %d: %v", last, err)
+		}
+	}
+
+	if len(done) > 0 {
+		log.Printf("%d messages finished out of order and were left unacknowledged", len(done))
+	}
+}
@@ -0,0 +1,233 @@
+package queue
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// recordingAcker records acknowledgements and checks that none covers an unfinished delivery
+type recordingAcker struct {
+	t        *testing.T
+	mu       sync.Mutex
+	finished map[uint64]bool
+	acks     []uint64
+}
+
+func newRecordingAcker(t *testing.T) *recordingAcker {
+	return &recordingAcker{t: t, finished: make(map[uint64]bool)}
+}
+
+func (a *recordingAcker) finish(tag uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.finished[tag] = true
+}
+
+func (a *recordingAcker) Ack(tag uint64, multiple bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !multiple {
+		a.t.Errorf("Ack(%d, false), want multiple acknowledgements", tag)
+	}
+	for t := uint64(1); t <= tag; t++ {
+		if !a.finished[t] {
+			a.t.Errorf("Ack(%d) while delivery %d is unfinished", tag, t)
+		}
+	}
+	if n := len(a.acks); n > 0 && tag <= a.acks[n-1] {
+		a.t.Errorf("Ack(%d) after Ack(%d)", tag, a.acks[n-1])
+	}
+	a.acks = append(a.acks, tag)
+	return nil
+}
+
+func (a *recordingAcker) last() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.acks) == 0 {
+		return 0
+	}
+	return a.acks[len(a.acks)-1]
+}
+
+func TestAckInOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		finished []uint64
+		wantAcks []uint64
+	}{
+		{"in order", []uint64{1, 2, 3}, []uint64{1, 2, 3}},
+		{"reversed", []uint64{3, 2, 1}, []uint64{3}},
+		{"gap filled last", []uint64{1, 3, 4, 2, 5}, []uint64{1, 4, 5}},
+		{"first unfinished", []uint64{2, 3, 4}, nil},
+		{"gap never filled", []uint64{1, 2, 4, 5}, []uint64{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acker := newRecordingAcker(t)
+			results := make(chan result)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				ackInOrder(acker, results)
+			}()
+			for _, tag := range tt.finished {
+				acker.finish(tag)
+				results <- result{tag: tag}
+			}
+			close(results)
+			<-done
+
+			if len(acker.acks) != len(tt.wantAcks) {
+				t.Fatalf("acks = %v, want %v", acker.acks, tt.wantAcks)
+			}
+			for i := range tt.wantAcks {
+				if acker.acks[i] != tt.wantAcks[i] {
+					t.Fatalf("acks = %v, want %v", acker.acks, tt.wantAcks)
+				}
+			}
+		})
+	}
+}
+
+func TestRunWorkersNeverAcksPastUnfinishedDelivery(t *testing.T) {
+	const deliveries = 20
+	acker := newRecordingAcker(t)
+	release := make(chan struct{})
+	othersDone := make(chan struct{})
+	var others sync.WaitGroup
+	others.Add(deliveries - 1)
+	go func() {
+		others.Wait()
+		close(othersDone)
+	}()
+
+	// The first delivery is held back until every later one has finished
+	process := func(body []byte, queueName string) error {
+		tag, _ := strconv.ParseUint(string(body), 10, 64)
+		if tag == 1 {
+			<-release
+		} else {
+			time.Sleep(time.Duration(deliveries-tag) * time.Millisecond) // Finish in reverse order
+			defer others.Done()
+		}
+		acker.finish(tag)
+		return nil
+	}
+
+	msgs := make(chan amqp.Delivery, deliveries)
+	for tag := uint64(1); tag <= deliveries; tag++ {
+		msgs <- amqp.Delivery{DeliveryTag: tag, Body: []byte(strconv.FormatUint(tag, 10))}
+	}
+	close(msgs)
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		RunWorkers(acker, msgs, "taxi_trips_raw", deliveries, process, nil)
+	}()
+
+	<-othersDone
+	time.Sleep(10 * time.Millisecond) // Let the acknowledger catch up with the finished deliveries
+	if last := acker.last(); last != 0 {
+		t.Fatalf("acknowledged up to %d while delivery 1 is unfinished", last)
+	}
+	close(release)
+	<-finished
+
+	if last := acker.last(); last != deliveries {
+		t.Errorf("acknowledged up to %d, want %d", last, deliveries)
+	}
+}
+
+func TestRunWorkersDeadLettersPanics(t *testing.T) {
+	acker := newRecordingAcker(t)
+	process := func(body []byte, queueName string) error {
+		tag, _ := strconv.ParseUint(string(body), 10, 64)
+		defer acker.finish(tag)
+		switch tag {
+		case 2:
+			var records []map[string]interface{}
+			_ = records[0]["trip_id"] // Index out of range
+		case 3:
+			return errors.New("invalid batch")
+		}
+		return nil
+	}
+
+	var mu sync.Mutex
+	var deadLetters []uint64
+	deadLetter := func(queueName string, msg amqp.Delivery, cause *PanicError) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if queueName != "taxi_trips_raw" || len(cause.Stack) == 0 {
+			t.Errorf("deadLetter(%q, stack of %d bytes)", queueName, len(cause.Stack))
+		}
+		deadLetters = append(deadLetters, msg.DeliveryTag)
+		return nil
+	}
+
+	msgs := make(chan amqp.Delivery, 4)
+	for tag := uint64(1); tag <= 4; tag++ {
+		msgs <- amqp.Delivery{DeliveryTag: tag, Body: []byte(strconv.FormatUint(tag, 10))}
+	}
+	close(msgs)
+	RunWorkers(acker, msgs, "taxi_trips_raw", 2, process, deadLetter)
+
+	if len(deadLetters) != 1 || deadLetters[0] != 2 {
+		t.Errorf("dead letters = %v, want only delivery 2", deadLetters)
+	}
+	if last := acker.last(); last != 4 {
+		t.Errorf("acknowledged up to %d, want 4", last)
+	}
+}
+
+func TestRunWorkersDecompresses(t *testing.T) {
+	t.Setenv("QUEUE_COMPRESSION", "gzip")
+	t.Setenv("QUEUE_COMPRESSION_THRESHOLD", "1")
+	want := `[{"trip_id":"a"},{"trip_id":"a"},{"trip_id":"a"},{"trip_id":"a"},{"trip_id":"a"}]`
+	body, encoding := Compress("taxi_trips_raw", []byte(want))
+
+	msgs := make(chan amqp.Delivery, 2)
+	msgs <- amqp.Delivery{DeliveryTag: 1, ContentEncoding: encoding, Body: body}
+	msgs <- amqp.Delivery{DeliveryTag: 2, ContentEncoding: "br", Body: body}
+	close(msgs)
+
+	var got []string
+	acker := newRecordingAcker(t)
+	acker.finish(1)
+	acker.finish(2)
+	RunWorkers(acker, msgs, "taxi_trips_raw", 1, func(body []byte, queueName string) error {
+		got = append(got, string(body))
+		return nil
+	}, nil)
+
+	// The message with an unsupported encoding is never processed, but still acknowledged
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("processed %q, want only %q", got, want)
+	}
+	if last := acker.last(); last != 2 {
+		t.Errorf("acknowledged up to %d, want 2", last)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	msg := amqp.Delivery{ContentType: "application/json", ContentEncoding: "zstd", Body: []byte("body")}
+	p := DeadLetter("taxi_trips_raw", msg, &PanicError{Value: "boom", Stack: []byte("stack")})
+	if p.ContentType != msg.ContentType || p.ContentEncoding != msg.ContentEncoding || string(p.Body) != "body" {
+		t.Errorf("DeadLetter() = %+v, want the message as received", p)
+	}
+	for header, want := range map[string]string{"x-original-queue": "taxi_trips_raw", "x-error": "panic: boom", "x-stack": "stack"} {
+		if p.Headers[header] != want {
+			t.Errorf("header %s = %v, want %q", header, p.Headers[header], want)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, p.Headers["x-failed-at"].(string)); err != nil {
+		t.Errorf("header x-failed-at: %v", err)
+	}
+}
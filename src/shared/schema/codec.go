@@ -0,0 +1,335 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// ContentType of messages encoded with Marshal
+const ContentType = "avro/binary"
+
+// Every message starts with the Avro single-object marker and the fingerprint of its record schema,
+// followed by the binary encoding of an array of records.
+var magic = []byte{0xC3, 0x01}
+
+const headerLen = 10
+
+// IsEncoded reports whether a message body was encoded with Marshal rather than plain JSON
+func IsEncoded(data []byte) bool {
+	return len(data) >= headerLen && bytes.Equal(data[:2], magic)
+}
+
+// Marshal encodes records with the latest schema of subject.
+// records may be any value that marshals to a JSON array of objects, such as a slice of
+// structs or of maps; values are converted to the type of their schema field.
+func Marshal(subject string, records interface{}) ([]byte, error) {
+	registry, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	s, err := registry.Latest(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.Marshal(records)
+}
+
+// Marshal encodes records with this schema
+func (s *Schema) Marshal(records interface{}) ([]byte, error) {
+	// Normalise the records to JSON objects so structs and maps are handled alike
+	raw, err := json.Marshal(records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal records: %w", err)
+	}
+	var objects []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, fmt.Errorf("records must be a list of objects: %w", err)
+	}
+
+	// Convert each value to its Avro native type
+	natives := make([]interface{}, len(objects))
+	for i, object := range objects {
+		native := make(map[string]interface{}, len(s.Fields))
+		for _, field := range s.Fields {
+			value, ok := object[field.Name]
+			if !ok {
+				if !field.HasDefault && !field.Nullable {
+					return nil, fmt.Errorf("%s record %d: missing field %s", s.Subject, i, field.Name)
+				}
+				value = field.Default
+			}
+			converted, err := field.native(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s record %d: %w", s.Subject, i, err)
+			}
+			native[field.Name] = converted
+		}
+		natives[i] = native
+	}
+
+	buf := make([]byte, headerLen, headerLen+len(raw)/2)
+	copy(buf, magic)
+	binary.LittleEndian.PutUint64(buf[2:headerLen], s.Fingerprint)
+	buf, err = s.batch.BinaryFromNative(buf, natives)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s records: %w", s.Subject, err)
+	}
+	return buf, nil
+}
+
+// Decode returns the latest schema of a message's subject and its records as Avro native values:
+// strings, int64, float64, bool, time.Time for timestamps and nil for nulls.
+// Records written with an older version are resolved to the latest version.
+func Decode(data []byte) (*Schema, []map[string]interface{}, error) {
+	registry, err := Default()
+	if err != nil {
+		return nil, nil, err
+	}
+	return registry.Decode(data)
+}
+
+// Decode returns the latest schema of a message's subject in this registry and its records,
+// resolved to that schema, as Decode does with the default registry
+func (r *Registry) Decode(data []byte) (*Schema, []map[string]interface{}, error) {
+	if !IsEncoded(data) {
+		return nil, nil, fmt.Errorf("message is not Avro encoded")
+	}
+	fingerprint := binary.LittleEndian.Uint64(data[2:headerLen])
+	s, ok := r.byFingerprint[fingerprint]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown schema fingerprint %x", fingerprint)
+	}
+
+	if err := checkBlockCount(data[headerLen:]); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode %s records: %w", s.Subject, err)
+	}
+	native, rest, err := s.batch.NativeFromBinary(data[headerLen:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode %s records: %w", s.Subject, err)
+	}
+	if len(rest) > 0 {
+		return nil, nil, fmt.Errorf("%d unexpected trailing bytes after %s records", len(rest), s.Subject)
+	}
+
+	items, _ := native.([]interface{})
+	records := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		record, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("unexpected %s record of type %T", s.Subject, item)
+		}
+		for name, value := range record {
+			record[name] = unwrapUnion(value)
+		}
+		records = append(records, record)
+	}
+	return r.resolve(s, records)
+}
+
+// checkBlockCount rejects a batch whose first block claims more records than it has bytes.
+// The decoder allocates room for the whole block up front, so a corrupt count would exhaust memory.
+func checkBlockCount(body []byte) error {
+	count, n := binary.Varint(body) // Avro longs are zigzag varints
+	if n <= 0 {
+		return fmt.Errorf("invalid block count")
+	}
+	if count < 0 {
+		count = -count // Followed by the block size in bytes
+	}
+	if count < 0 || count > int64(len(body)-n) {
+		return fmt.Errorf("block count %d exceeds the %d bytes of the message", count, len(body)-n)
+	}
+	return nil
+}
+
+// resolve converts records written with a version of a subject to its latest version:
+// fields added since are set to their default, removed fields are dropped and promoted values are converted
+func (r *Registry) resolve(writer *Schema, records []map[string]interface{}) (*Schema, []map[string]interface{}, error) {
+	latest, err := r.Latest(writer.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if latest == writer {
+		return writer, records, nil
+	}
+
+	writerTypes := make(map[string]string, len(writer.Fields))
+	for _, f := range writer.Fields {
+		writerTypes[f.Name] = f.Type
+	}
+	for i, record := range records {
+		resolved := make(map[string]interface{}, len(latest.Fields))
+		for _, f := range latest.Fields {
+			value, ok := record[f.Name]
+			if !ok {
+				resolved[f.Name] = f.Default
+				continue
+			}
+			if value != nil && writerTypes[f.Name] != f.Type {
+				if value, err = f.promote(value); err != nil {
+					return nil, nil, fmt.Errorf("%s record %d: failed to resolve version %d to %d: %w", writer.Subject, i, writer.Version, latest.Version, err)
+				}
+			}
+			resolved[f.Name] = value
+		}
+		records[i] = resolved
+	}
+	return latest, records, nil
+}
+
+// promote converts a value written with another type to the type of the field.
+// A string that is not a valid number, such as the empty string older versions wrote for
+// missing numbers, becomes null in a nullable number field and is an error in any other field.
+func (f Field) promote(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if f.Nullable && (f.Type == "int" || f.Type == "long" || f.Type == "float" || f.Type == "double") {
+			converted, err := convert(f.Type, strings.TrimSpace(v))
+			if err != nil {
+				if strings.TrimSpace(v) != "" {
+					log.Printf("Resolving %q of field %s as null: %v", v, f.Name, err)
+				}
+				return nil, nil
+			}
+			return converted, nil
+		}
+		value = strings.TrimSpace(v)
+	case []byte:
+		value = string(v)
+	case int32:
+		value = json.Number(strconv.FormatInt(int64(v), 10))
+	case int64:
+		value = json.Number(strconv.FormatInt(v, 10))
+	case float32:
+		value = json.Number(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		value = json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	converted, err := convert(f.Type, value)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", f.Name, err)
+	}
+	return converted, nil
+}
+
+// Unmarshal decodes a message into v, which is typically a pointer to a slice of structs or maps.
+// Plain JSON messages are accepted as well, in which case the returned schema is nil.
+func Unmarshal(data []byte, v interface{}) (*Schema, error) {
+	if !IsEncoded(data) {
+		return nil, json.Unmarshal(data, v)
+	}
+
+	s, records, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s records: %w", s.Subject, err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return nil, fmt.Errorf("failed to convert %s records: %w", s.Subject, err)
+	}
+	return s, nil
+}
+
+// unwrapUnion replaces goavro's {"type": value} union representation with the value itself
+func unwrapUnion(value interface{}) interface{} {
+	if union, ok := value.(map[string]interface{}); ok && len(union) == 1 {
+		for _, v := range union {
+			return v
+		}
+	}
+	return value
+}
+
+// native converts a JSON value to the Avro native value of the field
+func (f Field) native(value interface{}) (interface{}, error) {
+	if value == nil {
+		if f.Nullable {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("field %s: null value for a non-nullable field", f.Name)
+	}
+
+	converted, err := convert(f.Type, value)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", f.Name, err)
+	}
+	if f.Nullable {
+		return goavro.Union(f.branch, converted), nil
+	}
+	return converted, nil
+}
+
+// convert converts a JSON value to the Avro native value of an Avro type
+func convert(typ string, value interface{}) (interface{}, error) {
+	switch typ {
+	case "string":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case json.Number:
+			return v.String(), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+	case "long", "int":
+		switch v := value.(type) {
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				return i, nil
+			}
+			if f, err := v.Float64(); err == nil && f == float64(int64(f)) {
+				return int64(f), nil
+			}
+			return nil, fmt.Errorf("%s is not an integer", v)
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an integer", v)
+			}
+			return i, nil
+		}
+	case "double", "float":
+		switch v := value.(type) {
+		case json.Number:
+			return v.Float64()
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a number", v)
+			}
+			return f, nil
+		}
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+	case "timestamp-micros", "timestamp-millis":
+		switch v := value.(type) {
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an RFC 3339 timestamp", v)
+			}
+			return t, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s", value, typ)
+}
@@ -0,0 +1,172 @@
+package schema
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalDecodeRoundTrip(t *testing.T) {
+	r := testRegistry(t)
+	latest, _ := r.Latest("trips-bronze")
+
+	type trip struct {
+		Trip_id    string     `json:"trip_id"`
+		Miles      *float64   `json:"miles"`
+		Fare       float64    `json:"fare"`
+		Started_at *time.Time `json:"started_at"`
+		Shared     bool       `json:"shared"`
+	}
+	miles := 2.5
+	started := time.Date(2023, 6, 1, 13, 15, 0, 0, time.UTC)
+	data, err := latest.Marshal([]trip{
+		{Trip_id: "a", Miles: &miles, Fare: 11.25, Started_at: &started, Shared: true},
+		{Trip_id: "b", Fare: 7},
+	})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !IsEncoded(data) || binary.LittleEndian.Uint64(data[2:headerLen]) != latest.Fingerprint {
+		t.Fatalf("Marshal() header = %x, want the marker and fingerprint %x", data[:headerLen], latest.Fingerprint)
+	}
+
+	s, records, err := r.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if s != latest || len(records) != 2 {
+		t.Fatalf("Decode() = version %d with %d records, want version 2 with 2", s.Version, len(records))
+	}
+	if got := records[0]; got["trip_id"] != "a" || got["miles"] != 2.5 || got["fare"] != 11.25 || got["shared"] != true {
+		t.Errorf("record 0 = %v", got)
+	}
+	if got, ok := records[0]["started_at"].(time.Time); !ok || !got.Equal(started) {
+		t.Errorf("started_at = %v, want %s", records[0]["started_at"], started)
+	}
+	if got := records[1]; got["miles"] != nil || got["started_at"] != nil || got["shared"] != false {
+		t.Errorf("record 1 = %v, want null miles and started_at", got)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	latest, _ := testRegistry(t).Latest("trips-bronze")
+	tests := []struct {
+		name    string
+		records interface{}
+		wantErr string
+	}{
+		{"missing required field", []map[string]interface{}{{"trip_id": "a", "miles": nil}}, "missing field fare"},
+		{"null required field", []map[string]interface{}{{"trip_id": "a", "fare": nil}}, "null value"},
+		{"wrong type", []map[string]interface{}{{"trip_id": "a", "fare": "free"}}, "not a number"},
+		{"not a list", map[string]interface{}{"trip_id": "a"}, "list of objects"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := latest.Marshal(tt.records)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Marshal() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeResolvesOlderVersions(t *testing.T) {
+	r := testRegistry(t)
+	v1, _ := r.Version("trips-bronze", 1)
+
+	tests := []struct {
+		name      string
+		miles     interface{}
+		fare      string
+		wantMiles interface{}
+		wantFare  float64
+		wantErr   string
+	}{
+		{"numbers written as strings", "2.5", "11.25", 2.5, 11.25, ""},
+		{"padded numbers", " 3 ", " 7 ", 3.0, 7, ""},
+		{"null", nil, "7", nil, 7, ""},
+		{"empty string in a nullable field", "", "7", nil, 7, ""},
+		{"text in a nullable field", "n/a", "7", nil, 7, ""},
+		{"text in a required field", "2.5", "free", nil, 0, "field fare"},
+		{"empty string in a required field", "2.5", "", nil, 0, "field fare"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := v1.Marshal([]map[string]interface{}{{"trip_id": "a", "miles": tt.miles, "fare": tt.fare, "legacy": "x"}})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			s, records, err := r.Decode(data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "version 1 to 2") {
+					t.Errorf("Decode() error = %v, want one naming %q and the versions", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if s.Version != 2 {
+				t.Errorf("Decode() resolved to version %d, want 2", s.Version)
+			}
+			got := records[0]
+			if got["miles"] != tt.wantMiles || got["fare"] != tt.wantFare {
+				t.Errorf("miles, fare = %v, %v, want %v, %v", got["miles"], got["fare"], tt.wantMiles, tt.wantFare)
+			}
+			if _, ok := got["legacy"]; ok {
+				t.Errorf("removed field legacy = %v, want it dropped", got["legacy"])
+			}
+			if got["started_at"] != nil || got["shared"] != false {
+				t.Errorf("added fields = %v, %v, want their defaults", got["started_at"], got["shared"])
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	r := testRegistry(t)
+	latest, _ := r.Latest("trips-bronze")
+	valid, err := latest.Marshal([]map[string]interface{}{{"trip_id": "a", "fare": 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// header returns the marker and fingerprint of the latest version followed by body
+	header := func(fingerprint uint64, body ...byte) []byte {
+		data := make([]byte, headerLen, headerLen+len(body))
+		copy(data, magic)
+		binary.LittleEndian.PutUint64(data[2:], fingerprint)
+		return append(data, body...)
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"plain JSON", []byte(`[{"trip_id": "a"}]`), "not Avro encoded"},
+		{"unknown fingerprint", header(latest.Fingerprint+1, 0), "unknown schema fingerprint"},
+		{"record count larger than the message", header(latest.Fingerprint, binary.AppendVarint(nil, 1<<40)...), "exceeds"},
+		{"negative record count larger than the message", header(latest.Fingerprint, binary.AppendVarint(nil, -(1<<40))...), "exceeds"},
+		{"no record count", header(latest.Fingerprint), "invalid block count"},
+		{"truncated", valid[:len(valid)-2], "failed to decode"},
+		{"trailing bytes", append(append([]byte{}, valid...), 0x00), "trailing bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := r.Decode(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Decode() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnmarshalPlainJSON(t *testing.T) {
+	var records []map[string]interface{}
+	s, err := Unmarshal([]byte(`[{"trip_id": "a"}]`), &records)
+	if err != nil || s != nil || len(records) != 1 || records[0]["trip_id"] != "a" {
+		t.Errorf("Unmarshal() = %v, %v, %v, want the JSON records and no schema", s, records, err)
+	}
+}
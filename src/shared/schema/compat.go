@@ -0,0 +1,153 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Primitive types that a reader may read from data written with another type.
+// Besides the Avro promotions, a string may become a number, because the first versions of
+// several subjects wrote numbers as strings. This promotion is specific to this package: Decode
+// parses the strings of older records when it resolves them, while other Avro readers would
+// reject the schema change. A string that does not parse as the number becomes null when the
+// field is nullable, and fails the decoding of the message otherwise; see Field.promote.
+var promotions = map[string][]string{
+	"int":    {"long", "float", "double"},
+	"long":   {"float", "double"},
+	"float":  {"double"},
+	"string": {"bytes", "int", "long", "float", "double"},
+	"bytes":  {"string"},
+}
+
+// CheckCompatibility reports whether data written with the writer schema can be
+// read with the reader schema, following the Avro schema resolution rules:
+// fields added by the reader need a default, fields removed by the reader are
+// ignored, and field types may only change through a promotion listed in
+// promotions or by becoming nullable.
+func CheckCompatibility(writer, reader string) error {
+	var w, r interface{}
+	if err := json.Unmarshal([]byte(writer), &w); err != nil {
+		return fmt.Errorf("failed to parse writer schema: %w", err)
+	}
+	if err := json.Unmarshal([]byte(reader), &r); err != nil {
+		return fmt.Errorf("failed to parse reader schema: %w", err)
+	}
+	return checkType("", w, r)
+}
+
+// checkType checks one writer type against one reader type; at names the position for errors
+func checkType(at string, writer, reader interface{}) error {
+	// A writer union is readable if every branch is readable
+	if union, ok := writer.([]interface{}); ok {
+		for _, branch := range union {
+			if err := checkType(at, branch, reader); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// A reader union can read the writer type if any branch can
+	if union, ok := reader.([]interface{}); ok {
+		for _, branch := range union {
+			if checkType(at, writer, branch) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: no branch of %s can read %s", position(at), describe(reader), describe(writer))
+	}
+
+	wName, wLogical := typeName(writer)
+	rName, rLogical := typeName(reader)
+
+	if wName == "record" && rName == "record" {
+		return checkRecord(at, writer.(map[string]interface{}), reader.(map[string]interface{}))
+	}
+	if wName == "array" && rName == "array" {
+		return checkType(at+"[]", writer.(map[string]interface{})["items"], reader.(map[string]interface{})["items"])
+	}
+
+	if wLogical != rLogical {
+		return fmt.Errorf("%s: logical type changed from %q to %q", position(at), wLogical, rLogical)
+	}
+	if wName == rName {
+		return nil
+	}
+	for _, promoted := range promotions[wName] {
+		if promoted == rName {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: type changed from %s to %s", position(at), wName, rName)
+}
+
+// checkRecord checks that every reader field is either written or has a default
+func checkRecord(at string, writer, reader map[string]interface{}) error {
+	writerFields := make(map[string]interface{})
+	for _, f := range fieldList(writer) {
+		writerFields[f["name"].(string)] = f["type"]
+	}
+
+	for _, f := range fieldList(reader) {
+		name := f["name"].(string)
+		fieldAt := name
+		if at != "" {
+			fieldAt = at + "." + name
+		}
+
+		writerType, ok := writerFields[name]
+		if !ok {
+			if _, hasDefault := f["default"]; !hasDefault {
+				return fmt.Errorf("field %s was added without a default", fieldAt)
+			}
+			continue
+		}
+		if err := checkType(fieldAt, writerType, f["type"]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldList returns the fields of a parsed record schema
+func fieldList(record map[string]interface{}) []map[string]interface{} {
+	var fields []map[string]interface{}
+	list, _ := record["fields"].([]interface{})
+	for _, f := range list {
+		if field, ok := f.(map[string]interface{}); ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// typeName returns the Avro type name and logical type of a parsed type
+func typeName(t interface{}) (string, string) {
+	switch v := t.(type) {
+	case string:
+		return v, ""
+	case map[string]interface{}:
+		name, _ := v["type"].(string)
+		logical, _ := v["logicalType"].(string)
+		return name, logical
+	default:
+		return fmt.Sprintf("%v", v), ""
+	}
+}
+
+// describe formats a parsed type for error messages
+func describe(t interface{}) string {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Sprintf("%v", t)
+	}
+	return string(b)
+}
+
+// position names the location of a type for error messages
+func position(at string) string {
+	if at == "" {
+		return "schema"
+	}
+	return "field " + at
+}
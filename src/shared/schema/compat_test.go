@@ -0,0 +1,50 @@
+package schema
+
+import "testing"
+
+func TestCheckCompatibility(t *testing.T) {
+	record := func(fields string) string {
+		return `{"type": "record", "name": "Trip", "fields": [` + fields + `]}`
+	}
+	tests := []struct {
+		name    string
+		writer  string
+		reader  string
+		wantErr bool
+	}{
+		{"same schema", record(`{"name": "id", "type": "string"}`), record(`{"name": "id", "type": "string"}`), false},
+		{"field added with a default", record(`{"name": "id", "type": "string"}`),
+			record(`{"name": "id", "type": "string"}, {"name": "miles", "type": ["null", "double"], "default": null}`), false},
+		{"field added without a default", record(`{"name": "id", "type": "string"}`),
+			record(`{"name": "id", "type": "string"}, {"name": "miles", "type": ["null", "double"]}`), true},
+		{"field removed", record(`{"name": "id", "type": "string"}, {"name": "miles", "type": "double"}`),
+			record(`{"name": "id", "type": "string"}`), false},
+		{"int to long", record(`{"name": "n", "type": "int"}`), record(`{"name": "n", "type": "long"}`), false},
+		{"long to double", record(`{"name": "n", "type": "long"}`), record(`{"name": "n", "type": "double"}`), false},
+		{"long to int", record(`{"name": "n", "type": "long"}`), record(`{"name": "n", "type": "int"}`), true},
+		{"double to long", record(`{"name": "n", "type": "double"}`), record(`{"name": "n", "type": "long"}`), true},
+		{"double to string", record(`{"name": "n", "type": "double"}`), record(`{"name": "n", "type": "string"}`), true},
+		{"string to double", record(`{"name": "n", "type": "string"}`), record(`{"name": "n", "type": "double"}`), false},
+		{"nullable string to nullable long", record(`{"name": "n", "type": ["null", "string"]}`),
+			record(`{"name": "n", "type": ["null", "long"]}`), false},
+		{"string to boolean", record(`{"name": "n", "type": "string"}`), record(`{"name": "n", "type": "boolean"}`), true},
+		{"becomes nullable", record(`{"name": "n", "type": "long"}`), record(`{"name": "n", "type": ["null", "long"]}`), false},
+		{"stops being nullable", record(`{"name": "n", "type": ["null", "long"]}`), record(`{"name": "n", "type": "long"}`), true},
+		{"logical type added", record(`{"name": "at", "type": "long"}`),
+			record(`{"name": "at", "type": {"type": "long", "logicalType": "timestamp-micros"}}`), true},
+		{"array items promoted", record(`{"name": "ids", "type": {"type": "array", "items": "int"}}`),
+			record(`{"name": "ids", "type": {"type": "array", "items": "long"}}`), false},
+		{"array items narrowed", record(`{"name": "ids", "type": {"type": "array", "items": "long"}}`),
+			record(`{"name": "ids", "type": {"type": "array", "items": "int"}}`), true},
+		{"invalid writer", `{`, record(`{"name": "id", "type": "string"}`), true},
+		{"invalid reader", record(`{"name": "id", "type": "string"}`), `{`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCompatibility(tt.writer, tt.reader)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckCompatibility() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+// Package schema holds the versioned Avro schemas of the records published by the services
+// and the helpers every service uses to encode and decode them.
+//
+// Schemas live in a file-based registry: one directory per subject (named
+// <dataset>-<stage>, for example taxi_trips-bronze, or after the records for
+// subjects shared by every dataset) containing one file per version (v1.avsc, v2.avsc, ...). The registry bundled with this package is
+// used unless SCHEMA_REGISTRY_DIR points at another directory with the same layout.
+// Every version must be backward compatible with the version before it, so
+// a consumer using the newest schema can always read records written with an older one.
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Pipeline stages with registered schemas
+const (
+	Bronze = "bronze"
+	Silver = "silver"
+)
+
+// Subjects shared by every dataset
+const (
+	Quarantine     = "quarantine"           // Rows rejected by the cleaner
+	DropSummary    = "drop_summary"         // Per-batch counts of rejected rows
+	QualityMetrics = "data_quality_metrics" // Per-batch data quality metrics
+)
+
+//go:embed avro
+var bundled embed.FS
+
+// Schema is one registered version of a subject's record schema
+type Schema struct {
+	Subject     string
+	Version     int
+	Fields      []Field
+	Fingerprint uint64 // CRC-64-AVRO fingerprint of the record schema
+
+	record *goavro.Codec // Codec for a single record
+	batch  *goavro.Codec // Codec for an array of records, the body of a message
+}
+
+// Field describes one field of a record schema
+type Field struct {
+	Name       string
+	Type       string // Avro primitive type, or the logical type when one is set, e.g. timestamp-micros
+	Nullable   bool   // The field is a union of null and Type
+	Default    interface{}
+	HasDefault bool
+
+	branch string // Union branch name used by goavro, e.g. long.timestamp-micros
+}
+
+// Registry holds every version of every subject
+type Registry struct {
+	subjects      map[string][]*Schema // Versions of each subject, oldest first
+	byFingerprint map[uint64]*Schema
+}
+
+// Subject returns the registry subject of a dataset at a pipeline stage
+func Subject(dataset, stage string) string {
+	return dataset + "-" + stage
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultRegistry *Registry
+	defaultErr      error
+)
+
+// Default returns the registry loaded from SCHEMA_REGISTRY_DIR, or the bundled registry if it is not set
+func Default() (*Registry, error) {
+	defaultOnce.Do(func() {
+		if dir := os.Getenv("SCHEMA_REGISTRY_DIR"); dir != "" {
+			defaultRegistry, defaultErr = Load(os.DirFS(dir))
+			return
+		}
+		sub, err := fs.Sub(bundled, "avro")
+		if err != nil {
+			defaultErr = err
+			return
+		}
+		defaultRegistry, defaultErr = Load(sub)
+	})
+	return defaultRegistry, defaultErr
+}
+
+// Load reads every subject directory of fsys and checks that each version
+// is backward compatible with the previous version of the same subject
+func Load(fsys fs.FS) (*Registry, error) {
+	r := &Registry{
+		subjects:      make(map[string][]*Schema),
+		byFingerprint: make(map[uint64]*Schema),
+	}
+
+	dirs, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema registry: %w", err)
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		subject := dir.Name()
+
+		files, err := fs.ReadDir(fsys, subject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read subject %s: %w", subject, err)
+		}
+		for _, file := range files {
+			version, ok := parseVersion(file.Name())
+			if !ok {
+				continue
+			}
+			raw, err := fs.ReadFile(fsys, path.Join(subject, file.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s/%s: %w", subject, file.Name(), err)
+			}
+			s, err := newSchema(subject, version, raw)
+			if err != nil {
+				return nil, err
+			}
+			r.subjects[subject] = append(r.subjects[subject], s)
+		}
+
+		// Order versions and check each one against its predecessor
+		versions := r.subjects[subject]
+		sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+		for i, s := range versions {
+			if s.Version != i+1 {
+				return nil, fmt.Errorf("subject %s: expected version %d, found %d", subject, i+1, s.Version)
+			}
+			if i > 0 {
+				if err := CheckCompatibility(versions[i-1].record.Schema(), s.record.Schema()); err != nil {
+					return nil, fmt.Errorf("subject %s: version %d is not backward compatible with version %d: %w", subject, s.Version, s.Version-1, err)
+				}
+			}
+			if other, ok := r.byFingerprint[s.Fingerprint]; ok {
+				return nil, fmt.Errorf("subject %s version %d duplicates %s version %d", subject, s.Version, other.Subject, other.Version)
+			}
+			r.byFingerprint[s.Fingerprint] = s
+		}
+	}
+
+	return r, nil
+}
+
+// Latest returns the newest version of a subject
+func (r *Registry) Latest(subject string) (*Schema, error) {
+	versions := r.subjects[subject]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("unknown schema subject: %s", subject)
+	}
+	return versions[len(versions)-1], nil
+}
+
+// Version returns a specific version of a subject
+func (r *Registry) Version(subject string, version int) (*Schema, error) {
+	versions := r.subjects[subject]
+	if version < 1 || version > len(versions) {
+		return nil, fmt.Errorf("unknown version %d of schema subject %s", version, subject)
+	}
+	return versions[version-1], nil
+}
+
+// parseVersion extracts N from a file named vN.avsc
+func parseVersion(name string) (int, bool) {
+	if !strings.HasPrefix(name, "v") || !strings.HasSuffix(name, ".avsc") {
+		return 0, false
+	}
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "v"), ".avsc"))
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// newSchema compiles a record schema and the codec for a batch of its records
+func newSchema(subject string, version int, raw []byte) (*Schema, error) {
+	record, err := goavro.NewCodec(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("subject %s version %d: invalid schema: %w", subject, version, err)
+	}
+	batch, err := goavro.NewCodec(fmt.Sprintf(`{"type":"array","items":%s}`, record.Schema()))
+	if err != nil {
+		return nil, fmt.Errorf("subject %s version %d: invalid batch schema: %w", subject, version, err)
+	}
+	fields, err := parseFields(raw)
+	if err != nil {
+		return nil, fmt.Errorf("subject %s version %d: %w", subject, version, err)
+	}
+
+	return &Schema{
+		Subject:     subject,
+		Version:     version,
+		Fields:      fields,
+		Fingerprint: record.Rabin,
+		record:      record,
+		batch:       batch,
+	}, nil
+}
+
+// parseFields lists the fields of a record schema with their types
+func parseFields(raw []byte) ([]Field, error) {
+	var record struct {
+		Type   string `json:"type"`
+		Fields []struct {
+			Name    string          `json:"name"`
+			Type    interface{}     `json:"type"`
+			Default json.RawMessage `json:"default"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	if record.Type != "record" {
+		return nil, fmt.Errorf("schema must be a record, found %q", record.Type)
+	}
+
+	var fields []Field
+	for _, f := range record.Fields {
+		field := Field{Name: f.Name}
+
+		// A nullable field is a union of null and one other type
+		typ := f.Type
+		if union, ok := typ.([]interface{}); ok {
+			var branches []interface{}
+			for _, branch := range union {
+				if branch == "null" {
+					field.Nullable = true
+					continue
+				}
+				branches = append(branches, branch)
+			}
+			if len(branches) != 1 {
+				return nil, fmt.Errorf("field %s: only unions of null and one type are supported", f.Name)
+			}
+			typ = branches[0]
+		}
+
+		switch t := typ.(type) {
+		case string:
+			field.Type = t
+			field.branch = t
+		case map[string]interface{}:
+			base, _ := t["type"].(string)
+			if logical, ok := t["logicalType"].(string); ok {
+				field.Type = logical
+				field.branch = base + "." + logical
+			} else {
+				field.Type = base
+				field.branch = base
+			}
+		default:
+			return nil, fmt.Errorf("field %s: unsupported type %v", f.Name, f.Type)
+		}
+
+		if len(f.Default) > 0 {
+			field.HasDefault = true
+			if err := json.Unmarshal(f.Default, &field.Default); err != nil {
+				return nil, fmt.Errorf("field %s: invalid default: %w", f.Name, err)
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
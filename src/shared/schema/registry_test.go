@@ -0,0 +1,122 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Versions of a test subject: v2 turns the strings of v1 into numbers, drops legacy and adds
+// started_at and shared with defaults
+const (
+	tripV1 = `{"type": "record", "name": "Trip", "fields": [
+		{"name": "trip_id", "type": "string"},
+		{"name": "miles", "type": ["null", "string"]},
+		{"name": "fare", "type": "string"},
+		{"name": "legacy", "type": ["null", "string"], "default": null}
+	]}`
+	tripV2 = `{"type": "record", "name": "Trip", "fields": [
+		{"name": "trip_id", "type": "string"},
+		{"name": "miles", "type": ["null", "double"]},
+		{"name": "fare", "type": "double"},
+		{"name": "started_at", "type": ["null", {"type": "long", "logicalType": "timestamp-micros"}], "default": null},
+		{"name": "shared", "type": "boolean", "default": false}
+	]}`
+)
+
+// registryFS returns a registry directory holding files, keyed by subject/vN.avsc
+func registryFS(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return fsys
+}
+
+// testRegistry loads the registry of the test subject trips-bronze
+func testRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r, err := Load(registryFS(map[string]string{"trips-bronze/v1.avsc": tripV1, "trips-bronze/v2.avsc": tripV2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"compatible versions", map[string]string{"trips-bronze/v1.avsc": tripV1, "trips-bronze/v2.avsc": tripV2}, ""},
+		{"other files are ignored", map[string]string{"trips-bronze/v1.avsc": tripV1, "trips-bronze/README.md": "notes", "README.md": "notes"}, ""},
+		{"missing version", map[string]string{"trips-bronze/v1.avsc": tripV1, "trips-bronze/v3.avsc": tripV2}, "expected version 2"},
+		{"incompatible version", map[string]string{"trips-bronze/v1.avsc": tripV2, "trips-bronze/v2.avsc": tripV1}, "not backward compatible"},
+		{"field added without a default", map[string]string{"trips-bronze/v1.avsc": tripV1,
+			"trips-bronze/v2.avsc": strings.Replace(tripV1, `"default": null`, `"default": null}, {"name": "tips", "type": "double"`, 1)}, "without a default"},
+		{"same schema in two subjects", map[string]string{"trips-bronze/v1.avsc": tripV1, "trips-silver/v1.avsc": tripV1}, "duplicates"},
+		{"invalid schema", map[string]string{"trips-bronze/v1.avsc": `{"type": "record"`}, "invalid schema"},
+		{"not a record", map[string]string{"trips-bronze/v1.avsc": `{"type": "array", "items": "string"}`}, "must be a record"},
+		{"unsupported union", map[string]string{"trips-bronze/v1.avsc": `{"type": "record", "name": "Trip", "fields": [
+			{"name": "n", "type": ["null", "long", "string"]}]}`}, "only unions of null and one type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(registryFS(tt.files))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Load() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Load() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistryVersions(t *testing.T) {
+	r := testRegistry(t)
+
+	latest, err := r.Latest("trips-bronze")
+	if err != nil || latest.Version != 2 {
+		t.Fatalf("Latest() = %+v, %v, want version 2", latest, err)
+	}
+	v1, err := r.Version("trips-bronze", 1)
+	if err != nil || v1.Version != 1 || v1.Fingerprint == latest.Fingerprint {
+		t.Fatalf("Version(1) = %+v, %v, want version 1 with its own fingerprint", v1, err)
+	}
+	if r.byFingerprint[v1.Fingerprint] != v1 || r.byFingerprint[latest.Fingerprint] != latest {
+		t.Error("versions are not registered by fingerprint")
+	}
+	for _, version := range []int{0, 3} {
+		if _, err := r.Version("trips-bronze", version); err == nil {
+			t.Errorf("Version(%d) succeeded, want an error", version)
+		}
+	}
+	if _, err := r.Latest("trips-silver"); err == nil {
+		t.Error("Latest() of an unknown subject succeeded, want an error")
+	}
+
+	fields := map[string]Field{}
+	for _, f := range latest.Fields {
+		fields[f.Name] = f
+	}
+	if f := fields["started_at"]; f.Type != "timestamp-micros" || !f.Nullable || !f.HasDefault || f.Default != nil {
+		t.Errorf("started_at = %+v, want a nullable timestamp-micros defaulting to null", f)
+	}
+	if f := fields["shared"]; f.Type != "boolean" || f.Nullable || f.Default != false {
+		t.Errorf("shared = %+v, want a boolean defaulting to false", f)
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	r, err := Default()
+	if err != nil {
+		t.Fatalf("Default() error = %v", err)
+	}
+	for _, subject := range []string{Subject("taxi_trips", Bronze), Subject("taxi_trips", Silver), Quarantine, DropSummary, QualityMetrics} {
+		if _, err := r.Latest(subject); err != nil {
+			t.Errorf("Latest(%s) error = %v", subject, err)
+		}
+	}
+}
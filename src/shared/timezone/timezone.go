@@ -0,0 +1,57 @@
+// Package timezone holds the America/Chicago time zone of the City of Chicago data portal,
+// whose timestamps are published as wall clocks without a zone.
+package timezone
+
+import (
+	"time"
+	_ "time/tzdata" // Bundle the time zone database, the service images have none
+)
+
+// Name of the time zone of the floating timestamps published by the data portal
+const Name = "America/Chicago"
+
+// Chicago is the America/Chicago time zone
+var Chicago = mustLoadLocation(Name)
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err) // The time zone database is embedded
+	}
+	return loc
+}
+
+// WallClock interprets the wall clock of a timestamp without a zone as Chicago time.
+// Wall clocks that occur twice when daylight saving time ends resolve to the first,
+// daylight time occurrence. Wall clocks skipped when daylight saving time starts are
+// read with the standard time offset, so 02:30 becomes 03:30 daylight time.
+func WallClock(wall time.Time) time.Time {
+	naive := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), time.UTC)
+
+	// The offsets in effect a day either side; transitions are months apart
+	_, before := naive.Add(-24 * time.Hour).In(Chicago).Zone()
+	_, after := naive.Add(24 * time.Hour).In(Chicago).Zone()
+
+	var valid []time.Time
+	for _, offset := range []int{before, after} {
+		t := naive.Add(-time.Duration(offset) * time.Second).In(Chicago)
+		if _, actual := t.Zone(); actual == offset {
+			valid = append(valid, t)
+		}
+	}
+
+	switch {
+	case len(valid) == 0:
+		// Skipped wall clock: read it with the offset before the transition
+		return naive.Add(-time.Duration(before) * time.Second).In(Chicago)
+	case len(valid) == 2 && valid[1].Before(valid[0]):
+		return valid[1]
+	default:
+		return valid[0]
+	}
+}
+
+// Date returns the Chicago calendar date of an instant, such as 2006-01-02
+func Date(t time.Time) string {
+	return t.In(Chicago).Format(time.DateOnly)
+}
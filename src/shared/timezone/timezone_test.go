@@ -0,0 +1,65 @@
+package timezone
+
+import (
+	"testing"
+	"time"
+)
+
+// Wall clocks published without a zone and the instants they are read as
+var wallClockTests = []struct {
+	name string
+	wall string
+	want string
+}{
+	{"summer", "2023-07-04T12:00:00", "2023-07-04T17:00:00Z"},
+	{"winter", "2023-01-15T09:00:00", "2023-01-15T15:00:00Z"},
+	{"before daylight saving time starts", "2023-03-12T01:59:59", "2023-03-12T07:59:59Z"},
+	{"skipped when daylight saving time starts", "2023-03-12T02:30:00", "2023-03-12T08:30:00Z"}, // 03:30 CDT
+	{"after daylight saving time starts", "2023-03-12T03:30:00", "2023-03-12T08:30:00Z"},
+	{"before daylight saving time ends", "2023-11-05T00:59:59", "2023-11-05T05:59:59Z"},
+	{"repeated when daylight saving time ends", "2023-11-05T01:30:00", "2023-11-05T06:30:00Z"}, // 01:30 CDT
+	{"after daylight saving time ends", "2023-11-05T02:00:00", "2023-11-05T08:00:00Z"},
+}
+
+func TestWallClock(t *testing.T) {
+	for _, tt := range wallClockTests {
+		t.Run(tt.name, func(t *testing.T) {
+			wall, err := time.Parse("2006-01-02T15:04:05", tt.wall)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := time.Parse(time.RFC3339, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := WallClock(wall)
+			if !got.Equal(want) {
+				t.Errorf("WallClock(%s) = %s, want %s", tt.wall, got.UTC().Format(time.RFC3339), tt.want)
+			}
+			if got.Location() != Chicago {
+				t.Errorf("WallClock(%s) is in %s, want %s", tt.wall, got.Location(), Chicago)
+			}
+		})
+	}
+}
+
+func TestDate(t *testing.T) {
+	tests := []struct {
+		instant string
+		want    string
+	}{
+		{"2023-06-04T05:00:00Z", "2023-06-04"}, // Midnight CDT
+		{"2023-06-04T04:59:59Z", "2023-06-03"},
+		{"2023-01-15T06:00:00Z", "2023-01-15"}, // Midnight CST
+		{"2023-01-15T05:59:59Z", "2023-01-14"},
+	}
+	for _, tt := range tests {
+		instant, err := time.Parse(time.RFC3339, tt.instant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Date(instant); got != tt.want {
+			t.Errorf("Date(%s) = %s, want %s", tt.instant, got, tt.want)
+		}
+	}
+}
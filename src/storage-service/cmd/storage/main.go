@@ -1,36 +1,73 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"shared/model"
+	sharedqueue "shared/queue"
 	"storage-service/internal/db"
 	"storage-service/internal/queue"
 )
 
+// Maximum time to wait for in-flight messages after a shutdown signal
+const shutdownTimeout = 45 * time.Second
+
+// Time to wait before restarting a consumer whose queue is missing or whose connection was lost
+const restartDelay = 15 * time.Second
+
 func main() {
+	// Cancel the root context on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Establish a persistent database connection
 	err := db.Connect()
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	defer db.Close() // Ensure it closes when main exits
 
-	// List of queues to consume from
-	queues := []string{"taxi_trips_silver", "covid_cases_silver", "covid_vulnerability_index_silver", "building_permits_silver", "census_data_silver", "transportation_trips_silver", "public_health_statistics_silver"}
+	// Store the canonical community areas for joins with the cleaned records
+	if err := db.StoreCommunityAreas(); err != nil {
+		log.Fatalf("Failed to store community areas: %v", err)
+	}
 
+	// Compare the daily citywide COVID-19 counts with the weekly counts by zip code
+	if err := queue.CreateTables(model.CovidCases, model.CovidDailyCases); err != nil {
+		log.Fatalf("Failed to create COVID-19 tables: %v", err)
+	}
+	if err := db.CreateCovidReconciliation(); err != nil {
+		log.Fatalf("Failed to create COVID-19 reconciliation view: %v", err)
+	}
+
+	// List of queues to consume from: the silver records, the rows rejected by the cleaner,
+	// their summaries and the data quality metrics of each batch
+	queues := append(model.Queues("silver"), model.Queues("quarantine")...)
+	queues = append(queues, model.DropSummaries, model.QualityMetrics)
+
+	var wg sync.WaitGroup
 	for _, queueName := range queues {
+		wg.Add(1)
 		go func(queueName string) {
-			for {
-				err := queue.StartConsumer(queueName, queue.ProcessMessage)
-				if err != nil {
-					log.Printf("Waiting for queue %s to appear: %v", queueName, err)
-				} else {
-					break
-				}
-			}
+			defer wg.Done()
+			sharedqueue.KeepConsuming(ctx, queueName, restartDelay, func(ctx context.Context) error {
+				return queue.StartConsumer(ctx, queueName, queue.ProcessMessage)
+			})
 		}(queueName)
 	}
 
-	// Prevent the main function from exiting immediately
-	select {}
+	// Run until a shutdown signal is received
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutdown signal received, finishing in-flight messages")
+	code := sharedqueue.WaitForShutdown(&wg, shutdownTimeout)
+
+	// Close the database once no consumer can write to it
+	db.Close()
+	os.Exit(code)
 }
@@ -5,4 +5,14 @@ go 1.23.4
 require (
 	github.com/lib/pq v1.10.9
 	github.com/streadway/amqp v1.1.0
+	shared v0.0.0
 )
+
+require (
+	github.com/golang/snappy v0.0.1 // indirect
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/linkedin/goavro/v2 v2.15.0 // indirect
+)
+
+// The shared module is built from the sibling directory
+replace shared => ../shared
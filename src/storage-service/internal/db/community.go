@@ -0,0 +1,38 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+
+	"shared/community"
+)
+
+// Table holding the canonical community areas the cleaned records refer to
+const communityAreasTable = "community_areas"
+
+// StoreCommunityAreas writes the bundled community area table to the database,
+// so records can be joined to the canonical names by community area number
+func StoreCommunityAreas() error {
+	areas, err := community.Areas()
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (number BIGINT PRIMARY KEY, name TEXT NOT NULL, aliases TEXT[]);", communityAreasTable)
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("error creating table %s: %v", communityAreasTable, err)
+	}
+
+	query = fmt.Sprintf(`INSERT INTO %s (number, name, aliases) VALUES ($1, $2, $3)
+		ON CONFLICT (number) DO UPDATE SET name = EXCLUDED.name, aliases = EXCLUDED.aliases;`, communityAreasTable)
+	for _, area := range areas {
+		if _, err := db.Exec(query, area.Number, area.Name, pq.Array(area.Aliases)); err != nil {
+			return fmt.Errorf("error storing community area %d: %v", area.Number, err)
+		}
+	}
+
+	log.Printf("Stored %d community areas", len(areas))
+	return nil
+}
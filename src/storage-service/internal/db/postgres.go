@@ -5,22 +5,32 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/lib/pq"
+	"shared/postgres"
+	"shared/timezone"
 )
 
 var db *sql.DB
 
+// Concurrent CREATE TABLE IF NOT EXISTS statements for the same table can conflict in Postgres
+var createTableMu sync.Mutex
+
+// Time zone of the wall clocks stored in TIMESTAMP columns by earlier versions
+const legacyTimeZone = timezone.Name
+
+// Columns known to exist, as table.column, guarded by createTableMu
+var knownColumns = make(map[string]bool)
+
 // Connect to Postgres database
 func Connect() error {
 	var err error
 
 	// Create a connection string
-	connStr := fmt.Sprintf("host=postgres port=5432 user=%s password=%s dbname=%s sslmode=disable",
-		os.Getenv("POSTGRES_USER"), os.Getenv("POSTGRES_PASSWORD"), os.Getenv("POSTGRES_DB"))
+	connStr := postgres.ConnString()
 
 	// Open a connection to the database
 	db, err = sql.Open("postgres", connStr)
@@ -50,7 +60,8 @@ func Close() {
 	fmt.Println("Database connection closed")
 }
 
-// CreateTable creates a new table in the database based on the JSON schema
+// CreateTable creates a new table in the database based on the JSON schema,
+// and adds the columns of the schema that an existing table lacks
 func CreateTable(tableName string, jsonSchema string) error {
 	// Parse the JSON schema
 	var schema map[string]string
@@ -68,16 +79,83 @@ func CreateTable(tableName string, jsonSchema string) error {
 	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", tableName, strings.Join(columns, ", "))
 
 	// Execute the SQL statement
+	createTableMu.Lock()
+	defer createTableMu.Unlock()
 	_, err = db.Exec(query)
 	if err != nil {
 		log.Fatalf("Error creating table: %q", err)
 		return fmt.Errorf("error creating table: %v", err)
 	}
 
+	// Add columns introduced by newer schema versions
+	for columnName, columnType := range schema {
+		column := tableName + "." + columnName
+		if knownColumns[column] {
+			continue
+		}
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s;", tableName, columnName, columnType)
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("error adding column %s: %v", column, err)
+		}
+		if err := migrateColumn(tableName, columnName, columnType); err != nil {
+			return err
+		}
+		knownColumns[column] = true
+	}
+
 	fmt.Println("Table created successfully or already exists")
 	return nil
 }
 
+// Column types that TEXT columns written by earlier versions are converted to
+var numericTypes = map[string]bool{"INTEGER": true, "BIGINT": true, "REAL": true, "DOUBLE PRECISION": true}
+
+// migrateColumn converts a column created by an earlier version to the type the schema now declares.
+// TIMESTAMP columns become TIMESTAMPTZ; earlier versions stored the Chicago wall clock of each
+// timestamp, so values are read in that zone like the cleaner reads them. TEXT columns become
+// numeric if all their values parse, and are left as they are otherwise.
+func migrateColumn(tableName, columnName, columnType string) error {
+	var dataType string
+	err := db.QueryRow(
+		"SELECT data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2;",
+		tableName, columnName,
+	).Scan(&dataType)
+	if err != nil {
+		return fmt.Errorf("error reading type of column %s.%s: %v", tableName, columnName, err)
+	}
+
+	var query string
+	switch {
+	case columnType == "TIMESTAMPTZ" && dataType == "timestamp without time zone":
+		query = fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE TIMESTAMPTZ USING %s;", tableName, columnName, wallClockInstant(columnName))
+	case numericTypes[columnType] && dataType == "text":
+		query = fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING NULLIF(BTRIM(%s), '')::%s;", tableName, columnName, columnType, columnName, columnType)
+	default:
+		return nil
+	}
+
+	if _, err := db.Exec(query); err != nil {
+		if dataType == "text" {
+			log.Printf("Keeping column %s.%s as TEXT, its values are not all %s: %v", tableName, columnName, columnType, err)
+			return nil
+		}
+		return fmt.Errorf("error converting column %s.%s to %s: %v", tableName, columnName, columnType, err)
+	}
+	log.Printf("Converted column %s.%s from %s to %s", tableName, columnName, dataType, columnType)
+	return nil
+}
+
+// wallClockInstant returns the SQL expression that reads a TIMESTAMP column as a wall clock in
+// legacyTimeZone, resolving daylight saving time transitions the way the cleaner does. Postgres reads
+// a wall clock skipped when daylight saving time starts with the standard time offset, as the cleaner
+// does, but a wall clock that occurs twice when it ends as its second, standard time occurrence; the
+// instant an hour earlier is taken when it shows the same wall clock, which is the first occurrence.
+func wallClockInstant(columnName string) string {
+	instant := fmt.Sprintf("(%s AT TIME ZONE '%s')", columnName, legacyTimeZone)
+	return fmt.Sprintf("CASE WHEN (%s - INTERVAL '1 hour') AT TIME ZONE '%s' = %s THEN %s - INTERVAL '1 hour' ELSE %s END",
+		instant, legacyTimeZone, columnName, instant, instant)
+}
+
 // AddRecords adds multiple records to the database
 func AddRecords(tableName string, records []map[string]interface{}) error {
 	if len(records) == 0 {
@@ -100,39 +178,12 @@ func AddRecords(tableName string, records []map[string]interface{}) error {
 		var recordPlaceholders []string
 		for _, column := range columns {
 			value := record[column]
-			// fmt.Printf("Key: %s, Value: %v\n", column, value)
-
-			// // Check for empty strings or -1 and replace with null
-			// if value == "" || value == "-1" {
-			// 	value = nil
-			// }
-
-			// Append the value depending on the type
+			// Append the value depending on the type; only null values are inserted as NULL
 			switch v := value.(type) {
-			case string:
-				if v != "" {
-					values = append(values, v)
-				} else {
-					values = append(values, nil) // Replace empty string with null
-				}
-			case float64:
-				if v != -1 {
-					values = append(values, v)
-				} else {
-					values = append(values, nil) // Replace -1 with null for float
-				}
-			case int64:
-				if v != -1 {
-					values = append(values, v)
-				} else {
-					values = append(values, nil) // Replace -1 with null for int
-				}
-			case time.Time:
-				if !v.IsZero() { // Check if it's a zero value time
-					values = append(values, v)
-				} else {
-					values = append(values, nil) // Replace zero value time with null
-				}
+			case nil:
+				values = append(values, nil)
+			case string, float64, int64, bool, time.Time:
+				values = append(values, v)
 			default:
 				return fmt.Errorf("unsupported type %T for column %s", v, column)
 			}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"shared/env"
+)
+
+// View comparing the citywide daily COVID-19 counts with the weekly counts by zip code
+const covidReconciliationView = "covid_cases_reconciliation"
+
+// Relative difference between the daily and weekly counts of a week that is not flagged,
+// unless COVID_RECONCILIATION_TOLERANCE sets another one
+const defaultReconciliationTolerance = 0.05
+
+// CreateCovidReconciliation defines a view with one row per week of the weekly zip code dataset,
+// holding the sums of its zip code totals next to the sums of the daily citywide counts of the
+// same week. A week is flagged as a discrepancy when the daily counts do not cover its seven days,
+// or when the cases or deaths differ by more than the tolerance, a fraction of the weekly total,
+// and by more than one. Both tables must exist; rows stored twice are counted once.
+func CreateCovidReconciliation() error {
+	tolerance := env.Float("COVID_RECONCILIATION_TOLERANCE", defaultReconciliationTolerance)
+
+	query := fmt.Sprintf(`CREATE OR REPLACE VIEW %[1]s AS
+		WITH weekly AS (
+			SELECT week_start, week_end,
+				COUNT(*) AS zip_codes,
+				SUM(cases_weekly) AS weekly_cases,
+				SUM(deaths_weekly) AS weekly_deaths
+			FROM (SELECT DISTINCT ON (row_id) * FROM covid_cases ORDER BY row_id) cases
+			GROUP BY week_start, week_end
+		), daily AS (
+			SELECT weekly.week_start,
+				COUNT(*) AS days,
+				SUM(cases_total) AS daily_cases,
+				SUM(deaths_total) AS daily_deaths
+			FROM weekly
+			JOIN (SELECT DISTINCT ON (lab_report_date) * FROM covid_daily_cases ORDER BY lab_report_date) days
+				ON days.lab_report_date >= weekly.week_start AND days.lab_report_date < weekly.week_end + INTERVAL '1 day'
+			GROUP BY weekly.week_start
+		), compared AS (
+			SELECT weekly.week_start, weekly.week_end, weekly.zip_codes,
+				COALESCE(daily.days, 0) AS days,
+				weekly.weekly_cases, daily.daily_cases, daily.daily_cases - weekly.weekly_cases AS cases_difference,
+				weekly.weekly_deaths, daily.daily_deaths, daily.daily_deaths - weekly.weekly_deaths AS deaths_difference
+			FROM weekly LEFT JOIN daily ON daily.week_start = weekly.week_start
+		)
+		SELECT *,
+			days < 7
+			OR ABS(COALESCE(cases_difference, 0)) > GREATEST(1, %[2]g * COALESCE(weekly_cases, 0))
+			OR ABS(COALESCE(deaths_difference, 0)) > GREATEST(1, %[2]g * COALESCE(weekly_deaths, 0)) AS discrepancy
+		FROM compared;`, covidReconciliationView, tolerance)
+
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("error creating view %s: %v", covidReconciliationView, err)
+	}
+
+	log.Printf("Created view %s with a tolerance of %g", covidReconciliationView, tolerance)
+	return nil
+}
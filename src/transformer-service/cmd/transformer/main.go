@@ -1,28 +1,66 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"shared/model"
+	sharedqueue "shared/queue"
 	"transformer-service/internal/queue"
+	"transformer-service/internal/transform"
 )
 
+// Maximum time to wait for in-flight messages after a shutdown signal
+const shutdownTimeout = 45 * time.Second
+
+// Time to wait before restarting a consumer whose queue is missing or whose connection was lost
+const restartDelay = 15 * time.Second
+
 func main() {
+	// Cancel the root context on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Fail fast on a reverse geocoding misconfiguration rather than on the first message
+	if err := transform.SetupGeocoder(); err != nil {
+		log.Fatalf("Failed to set up reverse geocoding: %v", err)
+	}
+	if err := transform.SetupAirports(); err != nil {
+		log.Fatalf("Failed to set up the airport geofences: %v", err)
+	}
+	if err := transform.SetupCovidHistory(); err != nil {
+		log.Fatalf("Failed to set up the COVID-19 history: %v", err)
+	}
+
 	// List of queues to consume from
-	queues := []string{"taxi_trips_bronze", "covid_cases_bronze", "covid_vulnerability_index_bronze", "building_permits_bronze", "census_data_bronze", "transportation_trips_bronze", "public_health_statistics_bronze"}
+	queues := model.Queues("bronze")
 
+	var wg sync.WaitGroup
 	for _, queueName := range queues {
+		wg.Add(1)
 		go func(queueName string) {
-			for {
-				err := queue.StartConsumer(queueName, queue.ProcessMessage)
-				if err != nil {
-					log.Printf("Waiting for queue %s to appear: %v", queueName, err)
-				} else {
-					break
-				}
-			}
+			defer wg.Done()
+			sharedqueue.KeepConsuming(ctx, queueName, restartDelay, func(ctx context.Context) error {
+				return queue.StartConsumer(ctx, queueName, queue.ProcessMessage)
+			})
 		}(queueName)
 	}
 
-	// Prevent the main function from exiting immediately
-	select {}
+	// Run until a shutdown signal is received
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutdown signal received, finishing in-flight messages")
+	code := sharedqueue.WaitForShutdown(&wg, shutdownTimeout)
+
+	// Close the geocoding cache and the COVID-19 history once no worker can use them
+	if code == 0 {
+		transform.CloseGeocoder()
+		transform.CloseCovidHistory()
+	}
+	os.Exit(code)
 }
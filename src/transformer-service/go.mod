@@ -3,6 +3,17 @@ module transformer-service
 go 1.23.4
 
 require (
-	github.com/kelvins/geocoder v0.0.0-20231112130812-98d82c75e49b
 	github.com/streadway/amqp v1.1.0
+	go.etcd.io/bbolt v1.3.11
+	shared v0.0.0
 )
+
+require (
+	github.com/golang/snappy v0.0.1 // indirect
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/linkedin/goavro/v2 v2.15.0 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+)
+
+// The shared module is built from the sibling directory
+replace shared => ../shared
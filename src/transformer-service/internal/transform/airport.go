@@ -0,0 +1,73 @@
+package transform
+
+import (
+	"log"
+	"math"
+	"os"
+
+	"shared/geo"
+	"shared/model"
+)
+
+// Community areas of the airports: O'Hare (76) is the airport, and Garfield Ridge (56) holds Midway
+var airportCommunityAreas = map[int64]string{
+	76: "ORD",
+	56: "MDW",
+}
+
+// airportCentroid is a point the data portal gives trip ends located only by an airport's census tract
+// or community area. The centroids lie on the airfields, away from the terminal geofences.
+type airportCentroid struct {
+	lat, long float64
+	code      string
+}
+
+var airportCentroids = []airportCentroid{
+	{41.979070496, -87.903039661, "ORD"}, // Census tract 17031980000
+	{41.980264315, -87.913624596, "ORD"}, // Community area 76
+	{41.785998518, -87.750934289, "MDW"}, // Census tract 17031980100
+}
+
+// Largest distance in degrees at which a point is taken for a centroid, well under a metre
+const centroidTolerance = 1e-6
+
+// SetupAirports loads the airport geofences from the GeoJSON file at AIRPORT_GEOFENCES, or checks the
+// geofences bundled in the shared/geo package without it. Call it when the service starts, so invalid
+// geofences stop the service before it consumes any message.
+func SetupAirports() error {
+	path := os.Getenv("AIRPORT_GEOFENCES")
+	if path == "" {
+		_, err := geo.Airports()
+		return err
+	}
+	geofences, err := geo.ReadAirportsFile(path)
+	if err != nil {
+		return err
+	}
+	geo.SetAirports(geofences)
+	log.Printf("Loaded %d airport geofences from %s", len(geofences), path)
+	return nil
+}
+
+// airport returns the code of the airport at a trip end, ORD or MDW, or nil if it is not at an airport.
+// A trip end with coordinates is at an airport if its point lies in the airport's geofence or is one of
+// the airport's centroids. The community area is only used for trip ends without coordinates.
+func airport(lat, long *float64, communityArea *int64) *string {
+	if lat != nil && long != nil {
+		if code, ok := geo.AirportAt(*lat, *long); ok {
+			return model.Ptr(code)
+		}
+		for _, c := range airportCentroids {
+			if math.Abs(*lat-c.lat) <= centroidTolerance && math.Abs(*long-c.long) <= centroidTolerance {
+				return model.Ptr(c.code)
+			}
+		}
+		return nil
+	}
+	if communityArea != nil {
+		if code, ok := airportCommunityAreas[*communityArea]; ok {
+			return model.Ptr(code)
+		}
+	}
+	return nil
+}
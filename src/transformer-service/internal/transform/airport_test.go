@@ -0,0 +1,52 @@
+package transform
+
+import (
+	"path/filepath"
+	"testing"
+
+	"shared/model"
+)
+
+func TestAirport(t *testing.T) {
+	tests := []struct {
+		name          string
+		lat, long     *float64
+		communityArea *int64
+		want          string
+	}{
+		{"O'Hare terminals", model.Ptr(41.9786), model.Ptr(-87.9048), model.Ptr(int64(76)), "ORD"},
+		{"Midway terminal", model.Ptr(41.7868), model.Ptr(-87.7435), model.Ptr(int64(56)), "MDW"},
+		{"Midway CTA station in West Elsdon", model.Ptr(41.7867), model.Ptr(-87.7379), model.Ptr(int64(62)), "MDW"},
+		{"Midway census tract centroid", model.Ptr(41.785998518), model.Ptr(-87.750934289), nil, "MDW"},
+		{"O'Hare census tract centroid", model.Ptr(41.979070496), model.Ptr(-87.903039661), model.Ptr(int64(76)), "ORD"},
+		{"O'Hare community area centroid", model.Ptr(41.980264315), model.Ptr(-87.913624596), model.Ptr(int64(76)), "ORD"},
+		{"Midway airfield next to the centroid", model.Ptr(41.7860), model.Ptr(-87.7509), model.Ptr(int64(56)), ""},
+		{"Garfield Ridge away from Midway", model.Ptr(41.8129), model.Ptr(-87.7674), model.Ptr(int64(56)), ""},
+		{"O'Hare community area away from the terminals", model.Ptr(41.9900), model.Ptr(-87.9350), model.Ptr(int64(76)), ""},
+		{"O'Hare community area without coordinates", nil, nil, model.Ptr(int64(76)), "ORD"},
+		{"Garfield Ridge without coordinates", nil, nil, model.Ptr(int64(56)), "MDW"},
+		{"West Elsdon without coordinates", nil, nil, model.Ptr(int64(62)), ""},
+		{"Loop", model.Ptr(41.8819), model.Ptr(-87.6278), model.Ptr(int64(32)), ""},
+		{"nothing known", nil, nil, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := airport(tt.lat, tt.long, tt.communityArea)
+			if (got == nil) != (tt.want == "") || (got != nil && *got != tt.want) {
+				t.Errorf("airport() = %v, want %q", model.Value(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestSetupAirports(t *testing.T) {
+	t.Setenv("AIRPORT_GEOFENCES", "")
+	if err := SetupAirports(); err != nil {
+		t.Errorf("SetupAirports() with the bundled geofences error = %v", err)
+	}
+
+	t.Setenv("AIRPORT_GEOFENCES", filepath.Join(t.TempDir(), "missing.geojson"))
+	if err := SetupAirports(); err == nil {
+		t.Error("SetupAirports() of a missing file error = nil, want an error")
+	}
+}
@@ -0,0 +1,263 @@
+package transform
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"shared/env"
+)
+
+// Defaults of the geocoding cache, set with GEOCODE_CACHE_SIZE and GEOCODE_CACHE_PRECISION
+const (
+	defaultCacheSize      = 100000 // Entries kept in memory
+	defaultCachePrecision = 4      // Decimal places of the rounded coordinates, about 10 metres
+)
+
+// CachedGeocoder remembers the zip codes resolved by another geocoder, keyed by coordinates
+// rounded to a number of decimal places. Entries are kept in a least recently used cache in
+// memory and, if a file is given, in a bbolt database that survives restarts. Points without
+// a zip code are cached as well; failed lookups are not.
+type CachedGeocoder struct {
+	next      ReverseGeocoder
+	precision int
+	memory    *lruCache
+	store     *bolt.DB // nil without a persistent cache
+	bucket    []byte
+
+	memoryHits atomic.Int64
+	storeHits  atomic.Int64
+	misses     atomic.Int64
+}
+
+// CacheStats counts the lookups of a CachedGeocoder since it was created
+type CacheStats struct {
+	MemoryHits int64
+	StoreHits  int64
+	Misses     int64
+}
+
+// Prefix of the buckets holding cached zip codes
+const cacheBucketPrefix = "zip_codes_"
+
+// NewCachedGeocoder caches the answers of next in memory, up to size entries, and in the bbolt
+// database at path unless it is empty. Entries are stored in a bucket named after the precision
+// and a fingerprint of the providers and their boundaries, so changing GEOCODERS,
+// ZIP_CODE_BOUNDARIES or the precision starts a new persistent cache; the buckets of
+// earlier configurations are deleted when the cache is opened.
+func NewCachedGeocoder(next ReverseGeocoder, size, precision int, path string) (*CachedGeocoder, error) {
+	c := &CachedGeocoder{
+		next:      next,
+		precision: precision,
+		memory:    newLRUCache(size),
+		bucket:    cacheBucket(precision, geocoderFingerprint(next)),
+	}
+	if path == "" {
+		return c, nil
+	}
+
+	store, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geocoding cache %s: %w", path, err)
+	}
+	var stale [][]byte
+	err = store.Update(func(tx *bolt.Tx) error {
+		err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if bytes.HasPrefix(name, []byte(cacheBucketPrefix)) && !bytes.Equal(name, c.bucket) {
+				stale = append(stale, append([]byte(nil), name...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range stale {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		_, err = tx.CreateBucketIfNotExists(c.bucket)
+		return err
+	})
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to prepare geocoding cache %s: %w", path, err)
+	}
+	if len(stale) > 0 {
+		log.Printf("Deleted %d geocoding cache buckets of earlier providers, boundaries or precisions", len(stale))
+	}
+	c.store = store
+	return c, nil
+}
+
+// cacheBucket names the bucket of the entries rounded to precision and answered by the geocoder
+// with fingerprint
+func cacheBucket(precision int, fingerprint string) []byte {
+	h := fnv.New64a()
+	h.Write([]byte(fingerprint))
+	return []byte(fmt.Sprintf("%sp%d_%016x", cacheBucketPrefix, precision, h.Sum64()))
+}
+
+// newCachedGeocoderFromEnv wraps next in a cache configured with GEOCODE_CACHE_SIZE,
+// GEOCODE_CACHE_PRECISION and GEOCODE_CACHE_FILE
+func newCachedGeocoderFromEnv(next ReverseGeocoder) (*CachedGeocoder, error) {
+	return NewCachedGeocoder(next,
+		env.Int("GEOCODE_CACHE_SIZE", defaultCacheSize),
+		env.Int("GEOCODE_CACHE_PRECISION", defaultCachePrecision),
+		os.Getenv("GEOCODE_CACHE_FILE"),
+	)
+}
+
+// ZipCode returns the cached zip code of the rounded point, asking the wrapped geocoder on a miss.
+// The wrapped geocoder is asked about the rounded point, so an entry does not depend on which
+// of the points rounding to it was seen first.
+func (c *CachedGeocoder) ZipCode(lat, long float64) (string, bool, error) {
+	lat, long = c.round(lat), c.round(long)
+	key := strconv.FormatFloat(lat, 'f', c.precision, 64) + "," + strconv.FormatFloat(long, 'f', c.precision, 64)
+
+	if zip, ok := c.memory.get(key); ok {
+		c.memoryHits.Add(1)
+		return zip, zip != "", nil
+	}
+	if zip, ok := c.load(key); ok {
+		c.storeHits.Add(1)
+		c.memory.add(key, zip)
+		return zip, zip != "", nil
+	}
+
+	c.misses.Add(1)
+	zip, ok, err := c.next.ZipCode(lat, long)
+	if err != nil {
+		return "", false, err
+	}
+	if !ok {
+		zip = "" // The point has no zip code
+	}
+	c.memory.add(key, zip)
+	c.save(key, zip)
+	return zip, ok, nil
+}
+
+func (c *CachedGeocoder) Name() string {
+	return "cached " + c.next.Name()
+}
+
+// Stats returns the number of lookups answered from memory, from the persistent cache and by the wrapped geocoder
+func (c *CachedGeocoder) Stats() CacheStats {
+	return CacheStats{
+		MemoryHits: c.memoryHits.Load(),
+		StoreHits:  c.storeHits.Load(),
+		Misses:     c.misses.Load(),
+	}
+}
+
+// Close closes the persistent cache
+func (c *CachedGeocoder) Close() error {
+	if c.store == nil {
+		return nil
+	}
+	return c.store.Close()
+}
+
+// round rounds a coordinate to the precision of the cache
+func (c *CachedGeocoder) round(value float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(value, 'f', c.precision, 64), 64)
+	return rounded
+}
+
+// load reads an entry of the persistent cache
+func (c *CachedGeocoder) load(key string) (string, bool) {
+	if c.store == nil {
+		return "", false
+	}
+	var zip string
+	var found bool
+	err := c.store.View(func(tx *bolt.Tx) error {
+		if value := tx.Bucket(c.bucket).Get([]byte(key)); value != nil {
+			zip, found = string(value), true
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read the geocoding cache: %v", err)
+		return "", false
+	}
+	return zip, found
+}
+
+// save writes an entry to the persistent cache; writes of concurrent workers are batched
+func (c *CachedGeocoder) save(key, zip string) {
+	if c.store == nil {
+		return
+	}
+	err := c.store.Batch(func(tx *bolt.Tx) error {
+		return tx.Bucket(c.bucket).Put([]byte(key), []byte(zip))
+	})
+	if err != nil {
+		log.Printf("Failed to write the geocoding cache: %v", err)
+	}
+}
+
+// String reports the hit rates of the cache
+func (s CacheStats) String() string {
+	total := s.MemoryHits + s.StoreHits + s.Misses
+	if total == 0 {
+		return "no lookups"
+	}
+	return fmt.Sprintf("%d lookups, %d memory hits, %d persistent hits, %d misses (%.1f%% hit rate)",
+		total, s.MemoryHits, s.StoreHits, s.Misses, 100*float64(s.MemoryHits+s.StoreHits)/float64(total))
+}
+
+// lruCache is a fixed-size map that evicts its least recently used entry, safe for concurrent use
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // Entries, most recently used first
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value string
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get returns the value of a key and marks it as recently used
+func (l *lruCache) get(key string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	element, ok := l.entries[key]
+	if !ok {
+		return "", false
+	}
+	l.order.MoveToFront(element)
+	return element.Value.(*lruEntry).value, true
+}
+
+// add sets the value of a key, evicting the least recently used entry if the cache is full
+func (l *lruCache) add(key, value string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if element, ok := l.entries[key]; ok {
+		element.Value.(*lruEntry).value = value
+		l.order.MoveToFront(element)
+		return
+	}
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+}
@@ -0,0 +1,240 @@
+package transform
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// countingGeocoder records the points it is asked about and answers from zips, or with err
+type countingGeocoder struct {
+	name  string // Provider name, counting if empty
+	mu    sync.Mutex
+	zips  map[[2]float64]string
+	err   error
+	asked [][2]float64
+}
+
+func (g *countingGeocoder) ZipCode(lat, long float64) (string, bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.asked = append(g.asked, [2]float64{lat, long})
+	if g.err != nil {
+		return "", false, g.err
+	}
+	zip, ok := g.zips[[2]float64{lat, long}]
+	return zip, ok, nil
+}
+
+func (g *countingGeocoder) Name() string {
+	if g.name == "" {
+		return "counting"
+	}
+	return g.name
+}
+
+func TestCachedGeocoderRounding(t *testing.T) {
+	next := &countingGeocoder{zips: map[[2]float64]string{
+		{41.8823, -87.6278}: "60602",
+		{41.8824, -87.6278}: "60603",
+	}}
+	c, err := NewCachedGeocoder(next, 10, 4, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lat, long float64
+		want      string
+	}{
+		{41.88231, -87.62781, "60602"},
+		{41.882349, -87.627849, "60602"}, // Rounds to the same key as the point before
+		{41.882351, -87.62779, "60603"},  // Just across the rounding boundary
+		{41.88244, -87.62775, "60603"},
+	}
+	for _, tt := range tests {
+		zip, ok, err := c.ZipCode(tt.lat, tt.long)
+		if err != nil || !ok || zip != tt.want {
+			t.Errorf("ZipCode(%v, %v) = %q, %v, %v, want %q", tt.lat, tt.long, zip, ok, err, tt.want)
+		}
+	}
+
+	// The wrapped geocoder is asked once per rounded point, about the rounded point
+	want := [][2]float64{{41.8823, -87.6278}, {41.8824, -87.6278}}
+	if len(next.asked) != len(want) {
+		t.Fatalf("wrapped geocoder asked %v, want %v", next.asked, want)
+	}
+	for i := range want {
+		if next.asked[i] != want[i] {
+			t.Errorf("wrapped geocoder asked %v, want %v", next.asked, want)
+		}
+	}
+	if stats := c.Stats(); stats.MemoryHits != 2 || stats.Misses != 2 {
+		t.Errorf("Stats() = %+v, want 2 memory hits and 2 misses", stats)
+	}
+}
+
+func TestCachedGeocoderPersistsAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geocode.db")
+
+	// First run: one point with a zip code, one outside every zip code
+	first := &countingGeocoder{zips: map[[2]float64]string{{41.8786, -87.6251}: "60604"}}
+	c, err := NewCachedGeocoder(first, 10, 4, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zip, ok, err := c.ZipCode(41.8786, -87.6251); zip != "60604" || !ok || err != nil {
+		t.Fatalf("ZipCode() = %q, %v, %v, want 60604", zip, ok, err)
+	}
+	if zip, ok, err := c.ZipCode(41.9, -87.5); zip != "" || ok || err != nil {
+		t.Fatalf("ZipCode() over the lake = %q, %v, %v, want no zip code", zip, ok, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Second run: both answers come from the file, the wrapped geocoder is never asked
+	second := &countingGeocoder{err: errors.New("unreachable")}
+	c, err = NewCachedGeocoder(second, 10, 4, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if zip, ok, err := c.ZipCode(41.87861, -87.62509); zip != "60604" || !ok || err != nil {
+		t.Errorf("ZipCode() after reopening = %q, %v, %v, want 60604", zip, ok, err)
+	}
+	if zip, ok, err := c.ZipCode(41.9, -87.5); zip != "" || ok || err != nil {
+		t.Errorf("ZipCode() over the lake after reopening = %q, %v, %v, want a cached miss", zip, ok, err)
+	}
+	if len(second.asked) != 0 {
+		t.Errorf("wrapped geocoder asked %v, want nothing", second.asked)
+	}
+	if stats := c.Stats(); stats.StoreHits != 2 {
+		t.Errorf("Stats() = %+v, want 2 persistent hits", stats)
+	}
+}
+
+func TestCachedGeocoderPrecisionStartsNewCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geocode.db")
+	c, err := NewCachedGeocoder(&countingGeocoder{zips: map[[2]float64]string{{41.8786, -87.6251}: "60604"}}, 10, 4, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ZipCode(41.8786, -87.6251)
+	c.Close()
+
+	next := &countingGeocoder{zips: map[[2]float64]string{{41.879, -87.625}: "60605"}}
+	c, err = NewCachedGeocoder(next, 10, 3, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if zip, _, _ := c.ZipCode(41.8786, -87.6251); zip != "60605" || len(next.asked) != 1 {
+		t.Errorf("ZipCode() at precision 3 = %q after %d lookups, want 60605 from the wrapped geocoder", zip, len(next.asked))
+	}
+}
+
+func TestCachedGeocoderProviderChangeMisses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geocode.db")
+	point := [2]float64{41.8786, -87.6251}
+	runs := []struct {
+		name      string
+		provider  ReverseGeocoder
+		wantZip   string
+		wantAsked bool
+	}{
+		// A negative answer of the first provider must not hide the zip code known to the next one
+		{"first provider", &countingGeocoder{name: "first"}, "", true},
+		{"changed provider", &countingGeocoder{name: "second", zips: map[[2]float64]string{point: "60604"}}, "60604", true},
+		{"same provider", &countingGeocoder{name: "second"}, "60604", false},
+		{"provider added to the chain", ChainGeocoder{&countingGeocoder{name: "second"}, &countingGeocoder{name: "first"}}, "", true},
+	}
+	for _, run := range runs {
+		c, err := NewCachedGeocoder(run.provider, 10, 4, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zip, _, err := c.ZipCode(point[0], point[1])
+		if err != nil || zip != run.wantZip {
+			t.Errorf("%s: ZipCode() = %q, %v, want %q", run.name, zip, err, run.wantZip)
+		}
+		if asked := c.Stats().Misses == 1; asked != run.wantAsked {
+			t.Errorf("%s: wrapped geocoder asked = %v, want %v", run.name, asked, run.wantAsked)
+		}
+		c.Close()
+	}
+
+	// Only the bucket of the last provider is left
+	store, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	var buckets []string
+	store.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			buckets = append(buckets, string(name))
+			return nil
+		})
+	})
+	want := string(cacheBucket(4, geocoderFingerprint(runs[len(runs)-1].provider)))
+	if len(buckets) != 1 || buckets[0] != want {
+		t.Errorf("buckets = %v, want only %s", buckets, want)
+	}
+}
+
+func TestOfflineGeocoderFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	fingerprint := func(path string) string {
+		g, err := NewOfflineGeocoder(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return geocoderFingerprint(g)
+	}
+
+	a := fingerprint(writeZipBoundaries(t, dir, "a.geojson", "60604"))
+	sameAsA := fingerprint(writeZipBoundaries(t, dir, "copy.geojson", "60604"))
+	b := fingerprint(writeZipBoundaries(t, dir, "b.geojson", "60605"))
+	if a != sameAsA {
+		t.Errorf("fingerprints of identical boundaries = %q and %q, want equal", a, sameAsA)
+	}
+	if a == b {
+		t.Errorf("fingerprints of different boundaries = %q, want them to differ", a)
+	}
+}
+
+func TestCachedGeocoderDoesNotCacheErrors(t *testing.T) {
+	next := &countingGeocoder{err: errors.New("timeout")}
+	c, err := NewCachedGeocoder(next, 10, 4, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, err := c.ZipCode(41.8786, -87.6251); err == nil {
+			t.Fatal("ZipCode() error = nil, want the error of the wrapped geocoder")
+		}
+	}
+	if len(next.asked) != 2 {
+		t.Errorf("wrapped geocoder asked %d times, want 2", len(next.asked))
+	}
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	l := newLRUCache(2)
+	l.add("a", "1")
+	l.add("b", "2")
+	l.get("a")
+	l.add("c", "3")
+	if _, ok := l.get("b"); ok {
+		t.Error("b was kept, want it evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := l.get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+}
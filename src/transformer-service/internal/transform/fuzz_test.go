@@ -0,0 +1,92 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"shared/model"
+	sharedqueue "shared/queue"
+	"shared/schema"
+)
+
+// Bronze records as published by the cleaner
+const (
+	bronzeTaxiTrips = `[{"trip_id": "0a1b2c", "trip_start_timestamp": "2023-06-01T13:15:00Z", "trip_end_timestamp": "2023-06-01T13:30:00Z",
+		"trip_duration_seconds": 900, "pickup_centroid_latitude": 41.899602111, "pickup_centroid_longitude": -87.633308037,
+		"pickup_community_area": 8, "pickup_community_area_name": "Near North Side", "dropoff_centroid_latitude": 41.979070663,
+		"dropoff_centroid_longitude": -87.903039661, "dropoff_community_area": 76, "dropoff_community_area_name": "O'Hare"}]`
+	bronzeCovidCases = `[{"zip_code": "60601", "week_number": "10", "week_start": "2021-03-07T06:00:00Z", "week_end": "2021-03-13T06:00:00Z",
+		"cases_weekly": 12, "case_rate_weekly": 82.5, "percent_tested_positive_weekly": 0.029, "population": 14546,
+		"row_id": "60601-2021-10", "latitude": 41.886262, "longitude": -87.622844},
+		{"zip_code": "60601", "week_number": "11", "week_start": "2021-03-14T05:00:00Z", "week_end": "2021-03-20T05:00:00Z",
+		"cases_weekly": 20, "case_rate_weekly": 137.5, "row_id": "60601-2021-11"}]`
+	bronzeBuildingPermits = `[{"id": "3036421", "permit_type": "PERMIT - NEW CONSTRUCTION", "application_start_date": "2023-01-05T06:00:00Z",
+		"issue_date": "2023-02-01T06:00:00Z", "total_fee": 1250.5, "latitude": 41.88, "longitude": -87.63, "community_area": 32}]`
+	bronzeVulnerabilityIndex = `[{"geography_type": "ZIP", "community_area_or_zip": "60629", "ccvi_score": 63.1, "ccvi_category": "HIGH", "zip_code": "60629"}]`
+)
+
+// FuzzTransformData checks that no bronze message, JSON or Avro, however malformed, makes the
+// transformer panic: bad input must come back as an error, never take the panic path that moves
+// the message to the dead-letter queue.
+func FuzzTransformData(f *testing.F) {
+	f.Setenv("GEOCODERS", providerFake)
+	f.Setenv("GEOCODE_CACHE_FILE", "")
+
+	seeds := []struct {
+		dataset string
+		records string
+	}{
+		{model.TaxiTrips, bronzeTaxiTrips},
+		{model.TransportationTrips, bronzeTaxiTrips},
+		{model.CovidCases, bronzeCovidCases},
+		{model.BuildingPermits, bronzeBuildingPermits},
+		{model.CovidVulnerabilityIndex, bronzeVulnerabilityIndex},
+	}
+	for _, seed := range seeds {
+		index := datasetIndex(seed.dataset)
+		f.Add([]byte(seed.records), index)
+		f.Add([]byte(seed.records[:len(seed.records)/2]), index) // Truncated JSON
+
+		// The same records encoded with the bronze schema, whole and truncated
+		var records []map[string]interface{}
+		if err := json.Unmarshal([]byte(seed.records), &records); err != nil {
+			f.Fatal(err)
+		}
+		encoded, err := schema.Marshal(schema.Subject(seed.dataset, schema.Bronze), records)
+		if err != nil {
+			f.Fatalf("failed to encode %s seed: %v", seed.dataset, err)
+		}
+		f.Add(encoded, index)
+		f.Add(encoded[:len(encoded)-3], index)
+	}
+
+	// Mistyped records
+	f.Add([]byte(`[{"trip_id": 7, "trip_start_timestamp": "yesterday", "pickup_centroid_latitude": "41.9"}]`), datasetIndex(model.TaxiTrips))
+	f.Add([]byte(`{"zip_code": "60601"}`), datasetIndex(model.CovidCases))
+	f.Add([]byte(`[null, [], "row"]`), datasetIndex(model.BuildingPermits))
+	f.Add([]byte(`[{"pickup_centroid_latitude": 1e308, "pickup_centroid_longitude": -1e308}]`), datasetIndex(model.TaxiTrips))
+
+	f.Fuzz(func(t *testing.T, message []byte, dataset uint8) {
+		source := model.Datasets[int(dataset)%len(model.Datasets)]
+		err := sharedqueue.ProcessSafely(func(body []byte, queueName string) error {
+			_, err := TransformData(body, source)
+			return err
+		}, message, source+"_bronze")
+
+		var pe *sharedqueue.PanicError
+		if errors.As(err, &pe) {
+			t.Fatalf("TransformData(%q, %s) panicked: %v\n%s", message, source, pe.Value, pe.Stack)
+		}
+	})
+}
+
+// datasetIndex returns the index of a dataset in model.Datasets
+func datasetIndex(dataset string) uint8 {
+	for i, d := range model.Datasets {
+		if d == dataset {
+			return uint8(i)
+		}
+	}
+	panic("unknown dataset " + dataset)
+}
@@ -0,0 +1,415 @@
+package transform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/geo"
+	"shared/model"
+)
+
+// ReverseGeocoder resolves a point to the zip code that contains it
+type ReverseGeocoder interface {
+	// ZipCode returns the zip code of a point. ok is false when the provider has no zip code
+	// for the point, and err is set when the provider could not be asked.
+	ZipCode(lat, long float64) (zip string, ok bool, err error)
+	Name() string
+}
+
+// fingerprinter is implemented by geocoders whose answers depend on more than their provider,
+// such as the boundaries or the service they are looked up in
+type fingerprinter interface {
+	Fingerprint() string
+}
+
+// geocoderFingerprint identifies the answers of a geocoder, so that cached answers are not reused
+// once the provider or its data change
+func geocoderFingerprint(g ReverseGeocoder) string {
+	if f, ok := g.(fingerprinter); ok {
+		return f.Fingerprint()
+	}
+	return g.Name()
+}
+
+// Providers that can be listed in GEOCODERS
+const (
+	providerOffline   = "offline"
+	providerGoogle    = "google"
+	providerNominatim = "nominatim"
+	providerFake      = "fake"
+)
+
+// Public Nominatim instance used when NOMINATIM_URL is not set
+const defaultNominatimURL = "https://nominatim.openstreetmap.org"
+
+// Endpoint of the Google Maps Geocoding API
+const defaultGoogleURL = "https://maps.googleapis.com/maps/api/geocode/json"
+
+// Time allowed for a request to an HTTP provider
+const geocoderTimeout = 10 * time.Second
+
+// geocoderOnce guards the reverse geocoder, which is shared by all workers
+var geocoderOnce sync.Once
+
+var (
+	reverseGeocoder *CachedGeocoder
+	geocoderErr     error
+)
+
+// NewReverseGeocoder returns the providers listed in GEOCODERS, separated by commas, chained in order:
+// offline looks up the zip code boundaries in the GeoJSON file at ZIP_CODE_BOUNDARIES, google calls
+// the Google Maps API with GEOCODER_API_KEY, nominatim calls the Nominatim-compatible endpoint at
+// NOMINATIM_URL and fake returns made-up zip codes derived from the coordinates. Without GEOCODERS,
+// offline is used if ZIP_CODE_BOUNDARIES is set, followed by google if GEOCODER_API_KEY is set.
+func NewReverseGeocoder() (ReverseGeocoder, error) {
+	var providers []string
+	if list := os.Getenv("GEOCODERS"); list != "" {
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				providers = append(providers, name)
+			}
+		}
+	} else {
+		if os.Getenv("ZIP_CODE_BOUNDARIES") != "" {
+			providers = append(providers, providerOffline)
+		}
+		if os.Getenv("GEOCODER_API_KEY") != "" {
+			providers = append(providers, providerGoogle)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no reverse geocoder configured, set GEOCODERS, ZIP_CODE_BOUNDARIES or GEOCODER_API_KEY")
+	}
+
+	var chain ChainGeocoder
+	for _, name := range providers {
+		var g ReverseGeocoder
+		var err error
+		switch name {
+		case providerOffline:
+			g, err = NewOfflineGeocoder(os.Getenv("ZIP_CODE_BOUNDARIES"))
+		case providerGoogle:
+			g, err = NewGoogleGeocoder(os.Getenv("GEOCODER_API_KEY"))
+		case providerNominatim:
+			g, err = NewNominatimGeocoder(os.Getenv("NOMINATIM_URL"))
+		case providerFake:
+			g = &FakeGeocoder{}
+		default:
+			err = fmt.Errorf("unknown reverse geocoder %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, g)
+	}
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+	return chain, nil
+}
+
+// SetupGeocoder sets up the reverse geocoder shared by all workers. Call it when the service starts,
+// so a configuration error stops the service before it consumes any message.
+func SetupGeocoder() error {
+	_, err := sharedGeocoder()
+	return err
+}
+
+// sharedGeocoder returns the cached reverse geocoder shared by all workers, setting it up on first use
+func sharedGeocoder() (*CachedGeocoder, error) {
+	geocoderOnce.Do(func() {
+		providers, err := NewReverseGeocoder()
+		if err != nil {
+			geocoderErr = fmt.Errorf("failed to set up reverse geocoding: %w", err)
+			return
+		}
+		cached, err := newCachedGeocoderFromEnv(providers)
+		if err != nil {
+			geocoderErr = fmt.Errorf("failed to set up the geocoding cache: %w", err)
+			return
+		}
+		reverseGeocoder = cached
+		log.Printf("Resolving zip codes with %s", reverseGeocoder.Name())
+	})
+	return reverseGeocoder, geocoderErr
+}
+
+// zipCode returns the zip code of a point, or nil if it cannot be resolved
+func zipCode(g ReverseGeocoder, lat, long float64) *string {
+	zip, ok, err := g.ZipCode(lat, long)
+	if err != nil {
+		log.Printf("Failed to resolve the zip code of %f, %f: %v", lat, long, err)
+	}
+	if !ok {
+		return nil
+	}
+	return model.Ptr(zip)
+}
+
+// CloseGeocoder closes the geocoding cache; call it once no worker transforms records anymore
+func CloseGeocoder() {
+	if reverseGeocoder == nil {
+		return
+	}
+	log.Printf("Geocoding cache: %v", reverseGeocoder.Stats())
+	if err := reverseGeocoder.Close(); err != nil {
+		log.Printf("Failed to close the geocoding cache: %v", err)
+	}
+}
+
+// logGeocodingStats logs the hit rates of the geocoding cache since the service started
+func logGeocodingStats() {
+	if reverseGeocoder != nil {
+		log.Printf("Geocoding cache: %v", reverseGeocoder.Stats())
+	}
+}
+
+// ChainGeocoder asks each of its providers in turn until one has a zip code for the point
+type ChainGeocoder []ReverseGeocoder
+
+// ZipCode returns the first zip code found. Provider errors are logged and the next provider is asked;
+// the last error is only returned if no provider has a zip code.
+func (c ChainGeocoder) ZipCode(lat, long float64) (string, bool, error) {
+	var lastErr error
+	for _, g := range c {
+		zip, ok, err := g.ZipCode(lat, long)
+		if ok {
+			return zip, true, nil
+		}
+		if err != nil {
+			log.Printf("Reverse geocoder %s failed, trying the next one: %v", g.Name(), err)
+			lastErr = err
+		}
+	}
+	return "", false, lastErr
+}
+
+func (c ChainGeocoder) Name() string {
+	names := make([]string, len(c))
+	for i, g := range c {
+		names[i] = g.Name()
+	}
+	return strings.Join(names, " then ")
+}
+
+// Fingerprint identifies the providers of the chain and the data they answer from
+func (c ChainGeocoder) Fingerprint() string {
+	prints := make([]string, len(c))
+	for i, g := range c {
+		prints[i] = geocoderFingerprint(g)
+	}
+	return strings.Join(prints, " then ")
+}
+
+// OfflineGeocoder looks up points in zip code boundary polygons
+type OfflineGeocoder struct {
+	zipCodes *geo.ZipCodes
+	source   string // Hash of the boundary file
+}
+
+// NewOfflineGeocoder loads the zip code boundaries of a GeoJSON file
+func NewOfflineGeocoder(path string) (*OfflineGeocoder, error) {
+	if path == "" {
+		return nil, fmt.Errorf("the offline reverse geocoder needs ZIP_CODE_BOUNDARIES")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip code boundaries: %w", err)
+	}
+	zipCodes, err := geo.ReadZipCodes(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip code boundaries in %s: %w", path, err)
+	}
+	log.Printf("Loaded the boundaries of %d zip codes from %s", zipCodes.Len(), path)
+	sum := sha256.Sum256(data)
+	return &OfflineGeocoder{zipCodes: zipCodes, source: hex.EncodeToString(sum[:8])}, nil
+}
+
+func (g *OfflineGeocoder) ZipCode(lat, long float64) (string, bool, error) {
+	zip, ok := g.zipCodes.Lookup(lat, long)
+	return zip, ok, nil
+}
+
+func (g *OfflineGeocoder) Name() string { return providerOffline }
+
+// Fingerprint identifies the boundaries the zip codes are looked up in
+func (g *OfflineGeocoder) Fingerprint() string { return providerOffline + ":" + g.source }
+
+// GoogleGeocoder calls the reverse geocoding endpoint of the Google Maps Geocoding API
+type GoogleGeocoder struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client
+}
+
+// NewGoogleGeocoder returns a client of the Google Maps Geocoding API using apiKey
+func NewGoogleGeocoder(apiKey string) (*GoogleGeocoder, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("the google reverse geocoder needs GEOCODER_API_KEY")
+	}
+	return &GoogleGeocoder{
+		BaseURL: defaultGoogleURL,
+		APIKey:  apiKey,
+		Client:  &http.Client{Timeout: geocoderTimeout},
+	}, nil
+}
+
+func (g *GoogleGeocoder) ZipCode(lat, long float64) (string, bool, error) {
+	query := url.Values{
+		"latlng":      {strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(long, 'f', -1, 64)},
+		"result_type": {"postal_code"},
+		"key":         {g.APIKey},
+	}
+	resp, err := g.Client.Get(g.BaseURL + "?" + query.Encode())
+	if err != nil {
+		return "", false, fmt.Errorf("google request failed: %w", redactKey(err, g.APIKey))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("google returned %s", resp.Status)
+	}
+
+	var result struct {
+		Status       string `json:"status"`
+		ErrorMessage string `json:"error_message"`
+		Results      []struct {
+			AddressComponents []struct {
+				ShortName string   `json:"short_name"`
+				Types     []string `json:"types"`
+			} `json:"address_components"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", false, fmt.Errorf("invalid google response: %w", err)
+	}
+	switch result.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return "", false, nil // The point has no zip code, such as a point in the lake
+	default:
+		// OVER_QUERY_LIMIT, REQUEST_DENIED, INVALID_REQUEST or UNKNOWN_ERROR
+		return "", false, fmt.Errorf("google returned %s: %s", result.Status, result.ErrorMessage)
+	}
+
+	for _, r := range result.Results {
+		for _, component := range r.AddressComponents {
+			if slices.Contains(component.Types, "postal_code") && component.ShortName != "" {
+				return component.ShortName, true, nil
+			}
+		}
+	}
+	return "", false, nil
+}
+
+func (g *GoogleGeocoder) Name() string { return providerGoogle }
+
+// redactKey removes the API key from an error of the HTTP client, which quotes the request URL
+func redactKey(err error, apiKey string) error {
+	if apiKey == "" || !strings.Contains(err.Error(), apiKey) {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), apiKey, "REDACTED"))
+}
+
+// NominatimGeocoder calls the reverse endpoint of a Nominatim-compatible HTTP service.
+// The public instance allows one request per second; a self-hosted instance suits the pipeline better.
+type NominatimGeocoder struct {
+	BaseURL   string
+	UserAgent string // Nominatim asks clients to identify themselves
+	Client    *http.Client
+}
+
+// NewNominatimGeocoder returns a client for the service at baseURL, or the public instance if it is empty
+func NewNominatimGeocoder(baseURL string) (*NominatimGeocoder, error) {
+	if baseURL == "" {
+		baseURL = defaultNominatimURL
+	}
+	if _, err := url.ParseRequestURI(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid NOMINATIM_URL %q: %w", baseURL, err)
+	}
+	return &NominatimGeocoder{
+		BaseURL:   strings.TrimSuffix(baseURL, "/"),
+		UserAgent: "chicago-business-intelligence-transformer",
+		Client:    &http.Client{Timeout: geocoderTimeout},
+	}, nil
+}
+
+func (g *NominatimGeocoder) ZipCode(lat, long float64) (string, bool, error) {
+	query := url.Values{
+		"format":         {"jsonv2"},
+		"lat":            {strconv.FormatFloat(lat, 'f', -1, 64)},
+		"lon":            {strconv.FormatFloat(long, 'f', -1, 64)},
+		"zoom":           {"18"},
+		"addressdetails": {"1"},
+	}
+	req, err := http.NewRequest(http.MethodGet, g.BaseURL+"/reverse?"+query.Encode(), nil)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("User-Agent", g.UserAgent)
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("nominatim returned %s", resp.Status)
+	}
+
+	var result struct {
+		Error   string `json:"error"`
+		Address struct {
+			Postcode string `json:"postcode"`
+		} `json:"address"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", false, fmt.Errorf("invalid nominatim response: %w", err)
+	}
+	if result.Error != "" {
+		return "", false, nil // Nominatim reports points it cannot place as an error, such as "Unable to geocode"
+	}
+
+	// Keep the five digit zip code of a ZIP+4 code
+	zip, _, _ := strings.Cut(strings.TrimSpace(result.Address.Postcode), "-")
+	if zip == "" {
+		return "", false, nil
+	}
+	return zip, true, nil
+}
+
+func (g *NominatimGeocoder) Name() string { return providerNominatim }
+
+// Fingerprint identifies the service that is asked, since instances may hold different data
+func (g *NominatimGeocoder) Fingerprint() string { return providerNominatim + ":" + g.BaseURL }
+
+// FakeGeocoder returns zip codes without looking anything up, for tests and local runs.
+// Points listed in Zips get their listed zip code; any other point gets a Chicago-looking
+// zip code, 60601 to 60699, derived from its coordinates, so the same point always gets the same one.
+type FakeGeocoder struct {
+	Zips map[[2]float64]string // Zip codes by latitude and longitude
+}
+
+func (g *FakeGeocoder) ZipCode(lat, long float64) (string, bool, error) {
+	if zip, ok := g.Zips[[2]float64{lat, long}]; ok {
+		return zip, true, nil
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%.6f,%.6f", lat, long)
+	return fmt.Sprintf("606%02d", h.Sum32()%99+1), true, nil
+}
+
+func (g *FakeGeocoder) Name() string { return providerFake }
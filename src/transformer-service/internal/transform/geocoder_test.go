@@ -0,0 +1,258 @@
+package transform
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeZipBoundaries writes a GeoJSON file in dir holding one square zip code boundary around the Loop
+func writeZipBoundaries(t *testing.T, dir, name, zip string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	boundary := `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{"zip":"` + zip + `"},` +
+		`"geometry":{"type":"Polygon","coordinates":[[[-87.7,41.8],[-87.6,41.8],[-87.6,41.9],[-87.7,41.9],[-87.7,41.8]]]}}]}`
+	if err := os.WriteFile(path, []byte(boundary), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOfflineGeocoder(t *testing.T) {
+	g, err := NewOfflineGeocoder(writeZipBoundaries(t, t.TempDir(), "zip_codes.geojson", "60602"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zip, ok, err := g.ZipCode(41.8840, -87.6302); err != nil || !ok || zip != "60602" {
+		t.Errorf("ZipCode() in the boundary = %q, %v, %v, want 60602", zip, ok, err)
+	}
+	if zip, ok, err := g.ZipCode(42.0450, -87.6900); err != nil || ok {
+		t.Errorf("ZipCode() outside the boundary = %q, %v, %v, want no zip code", zip, ok, err)
+	}
+
+	if _, err := NewOfflineGeocoder(""); err == nil {
+		t.Error("NewOfflineGeocoder() without boundaries error = nil, want an error")
+	}
+	if _, err := NewOfflineGeocoder(filepath.Join(t.TempDir(), "missing.geojson")); err == nil {
+		t.Error("NewOfflineGeocoder() of a missing file error = nil, want an error")
+	}
+}
+
+func TestNewReverseGeocoderProviders(t *testing.T) {
+	boundaries := writeZipBoundaries(t, t.TempDir(), "zip_codes.geojson", "60602")
+	tests := []struct {
+		name       string
+		geocoders  string
+		boundaries string
+		apiKey     string
+		want       string
+		wantErr    bool
+	}{
+		{"boundaries by default", "", boundaries, "", "offline", false},
+		{"google after the boundaries", "", boundaries, "key", "offline then google", false},
+		{"google alone", "", "", "key", "google", false},
+		{"nothing configured", "", "", "", "", true},
+		{"listed providers", "fake, Offline", boundaries, "", "fake then offline", false},
+		{"offline without boundaries", "offline", "", "", "", true},
+		{"google without its key", "offline,google", boundaries, "", "", true},
+		{"unknown provider", "bing", "", "", "", true},
+		{"empty list", ",", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GEOCODERS", tt.geocoders)
+			t.Setenv("ZIP_CODE_BOUNDARIES", tt.boundaries)
+			t.Setenv("GEOCODER_API_KEY", tt.apiKey)
+
+			g, err := NewReverseGeocoder()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("NewReverseGeocoder() = %s, want an error", g.Name())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewReverseGeocoder() error = %v", err)
+			}
+			if g.Name() != tt.want {
+				t.Errorf("NewReverseGeocoder() = %s, want %s", g.Name(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFakeGeocoder(t *testing.T) {
+	g := &FakeGeocoder{Zips: map[[2]float64]string{{41.8840, -87.6302}: "60602"}}
+
+	if zip, ok, err := g.ZipCode(41.8840, -87.6302); err != nil || !ok || zip != "60602" {
+		t.Errorf("ZipCode() of a listed point = %q, %v, %v, want 60602", zip, ok, err)
+	}
+	zip, ok, err := g.ZipCode(41.9484, -87.6553)
+	if err != nil || !ok || len(zip) != 5 || zip < "60601" || zip > "60699" {
+		t.Fatalf("ZipCode() = %q, %v, %v, want a zip code from 60601 to 60699", zip, ok, err)
+	}
+	if again, _, _ := g.ZipCode(41.9484, -87.6553); again != zip {
+		t.Errorf("ZipCode() of the same point = %q, then %q", zip, again)
+	}
+}
+
+// geocoderServer serves body with status for every request and records the last request
+func geocoderServer(t *testing.T, status int, body string, last **http.Request) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*last = r
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNominatimGeocoder(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    string
+		wantOK  bool
+		wantErr bool
+	}{
+		{"zip code", http.StatusOK, `{"address": {"postcode": "60602"}}`, "60602", true, false},
+		{"ZIP+4 code", http.StatusOK, `{"address": {"postcode": " 60602-1234"}}`, "60602", true, false},
+		{"no postcode", http.StatusOK, `{"address": {"city": "Chicago"}}`, "", false, false},
+		{"point it cannot place", http.StatusOK, `{"error": "Unable to geocode"}`, "", false, false},
+		{"rate limited", http.StatusTooManyRequests, ``, "", false, true},
+		{"invalid body", http.StatusOK, `<html>`, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			srv := geocoderServer(t, tt.status, tt.body, &req)
+			g, err := NewNominatimGeocoder(srv.URL + "/")
+			if err != nil {
+				t.Fatalf("NewNominatimGeocoder() error = %v", err)
+			}
+
+			zip, ok, err := g.ZipCode(41.884, -87.6302)
+			if zip != tt.want || ok != tt.wantOK || (err != nil) != tt.wantErr {
+				t.Errorf("ZipCode() = %q, %v, %v, want %q, %v, error %v", zip, ok, err, tt.want, tt.wantOK, tt.wantErr)
+			}
+			if req.URL.Path != "/reverse" || req.URL.Query().Get("lat") != "41.884" || req.URL.Query().Get("lon") != "-87.6302" {
+				t.Errorf("request = %s, want /reverse with lat and lon", req.URL)
+			}
+			if req.UserAgent() != g.UserAgent {
+				t.Errorf("User-Agent = %q, want %q", req.UserAgent(), g.UserAgent)
+			}
+		})
+	}
+}
+
+func TestGoogleGeocoder(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    string
+		wantOK  bool
+		wantErr string
+	}{
+		{"zip code", http.StatusOK, `{"status": "OK", "results": [{"address_components": [
+			{"short_name": "Chicago", "types": ["locality", "political"]},
+			{"short_name": "60602", "types": ["postal_code"]}]}]}`, "60602", true, ""},
+		{"no results", http.StatusOK, `{"status": "ZERO_RESULTS", "results": []}`, "", false, ""},
+		{"no postal code", http.StatusOK, `{"status": "OK", "results": [{"address_components": [
+			{"short_name": "IL", "types": ["administrative_area_level_1"]}]}]}`, "", false, ""},
+		{"denied", http.StatusOK, `{"status": "REQUEST_DENIED", "error_message": "The provided API key is invalid."}`,
+			"", false, "REQUEST_DENIED: The provided API key is invalid."},
+		{"over the quota", http.StatusOK, `{"status": "OVER_QUERY_LIMIT"}`, "", false, "OVER_QUERY_LIMIT"},
+		{"server error", http.StatusInternalServerError, ``, "", false, "500"},
+		{"invalid body", http.StatusOK, `<html>`, "", false, "invalid google response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			srv := geocoderServer(t, tt.status, tt.body, &req)
+			g, err := NewGoogleGeocoder("secret")
+			if err != nil {
+				t.Fatalf("NewGoogleGeocoder() error = %v", err)
+			}
+			g.BaseURL = srv.URL
+
+			zip, ok, err := g.ZipCode(41.884, -87.6302)
+			if zip != tt.want || ok != tt.wantOK {
+				t.Errorf("ZipCode() = %q, %v, %v, want %q, %v", zip, ok, err, tt.want, tt.wantOK)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("ZipCode() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ZipCode() error = %v, want one containing %q", err, tt.wantErr)
+			}
+			query := req.URL.Query()
+			if query.Get("latlng") != "41.884,-87.6302" || query.Get("key") != "secret" {
+				t.Errorf("request = %s, want latlng and key", req.URL)
+			}
+		})
+	}
+}
+
+func TestGoogleGeocoderHidesKey(t *testing.T) {
+	g, err := NewGoogleGeocoder("secret")
+	if err != nil {
+		t.Fatalf("NewGoogleGeocoder() error = %v", err)
+	}
+	g.BaseURL = "http://127.0.0.1:0/geocode" // Nothing listens on port 0
+
+	_, _, err = g.ZipCode(41.884, -87.6302)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("ZipCode() error = %v, want one without the API key", err)
+	}
+}
+
+func TestChainGeocoder(t *testing.T) {
+	loop := [2]float64{41.8840, -87.6302}
+	failing := func() *countingGeocoder { return &countingGeocoder{err: errors.New("quota exceeded")} }
+	empty := func() *countingGeocoder { return &countingGeocoder{} }
+	knows := func() *countingGeocoder { return &countingGeocoder{zips: map[[2]float64]string{loop: "60602"}} }
+
+	tests := []struct {
+		name    string
+		chain   []*countingGeocoder
+		want    string
+		wantOK  bool
+		wantErr bool
+		asked   []int // Number of times each provider is asked
+	}{
+		{"first provider answers", []*countingGeocoder{knows(), failing()}, "60602", true, false, []int{1, 0}},
+		{"falls back after an error", []*countingGeocoder{failing(), knows()}, "60602", true, false, []int{1, 1}},
+		{"falls back when there is no zip code", []*countingGeocoder{empty(), knows()}, "60602", true, false, []int{1, 1}},
+		{"all providers fail", []*countingGeocoder{failing(), empty()}, "", false, true, []int{1, 1}},
+		{"no provider has a zip code", []*countingGeocoder{empty(), empty()}, "", false, false, []int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chain ChainGeocoder
+			for _, g := range tt.chain {
+				chain = append(chain, g)
+			}
+
+			zip, ok, err := chain.ZipCode(loop[0], loop[1])
+			if zip != tt.want || ok != tt.wantOK || (err != nil) != tt.wantErr {
+				t.Errorf("ZipCode() = %q, %v, %v, want %q, %v, error %v", zip, ok, err, tt.want, tt.wantOK, tt.wantErr)
+			}
+			for i, g := range tt.chain {
+				if len(g.asked) != tt.asked[i] {
+					t.Errorf("provider %d asked %d times, want %d", i, len(g.asked), tt.asked[i])
+				}
+			}
+		})
+	}
+
+	if name := (ChainGeocoder{&FakeGeocoder{}, &countingGeocoder{}}).Name(); name != "fake then counting" {
+		t.Errorf("Name() = %q, want fake then counting", name)
+	}
+}
@@ -0,0 +1,136 @@
+package transform
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"shared/timezone"
+)
+
+// Bucket of the weekly case rates in the COVID_HISTORY_FILE database
+var caseRatesBucket = []byte("covid_case_rates")
+
+// weeklyHistory remembers the weekly case rate of every zip code and week transformed, in memory
+// and, if a file is given, in a bbolt database that survives restarts, so a week finds the case
+// rate of the week before it even when that week was transformed in an earlier batch or process
+type weeklyHistory struct {
+	mu    sync.Mutex
+	rates map[string]float64 // Case rates by weekKey
+	store *bolt.DB           // nil without a persistent history
+}
+
+// openWeeklyHistory loads the case rates kept in the bbolt database at path, or starts an empty
+// history kept in memory only if path is empty
+func openWeeklyHistory(path string) (*weeklyHistory, error) {
+	h := &weeklyHistory{rates: make(map[string]float64)}
+	if path == "" {
+		return h, nil
+	}
+
+	store, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open COVID-19 history %s: %w", path, err)
+	}
+	err = store.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(caseRatesBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			rate, err := strconv.ParseFloat(string(value), 64)
+			if err != nil {
+				return fmt.Errorf("invalid case rate %q of %s: %w", value, key, err)
+			}
+			h.rates[string(key)] = rate
+			return nil
+		})
+	})
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to read COVID-19 history %s: %w", path, err)
+	}
+	h.store = store
+	log.Printf("Loaded %d weekly case rates from %s", len(h.rates), path)
+	return h, nil
+}
+
+// add records the case rates of a batch, by weekKey; the caller holds h.mu
+func (h *weeklyHistory) add(rates map[string]float64) error {
+	for key, rate := range rates {
+		h.rates[key] = rate
+	}
+	if h.store == nil || len(rates) == 0 {
+		return nil
+	}
+	err := h.store.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(caseRatesBucket)
+		for key, rate := range rates {
+			if err := bucket.Put([]byte(key), []byte(strconv.FormatFloat(rate, 'g', -1, 64))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write COVID-19 history: %w", err)
+	}
+	return nil
+}
+
+// rate returns the case rate of a zip code and week; the caller holds h.mu
+func (h *weeklyHistory) rate(zip string, weekStart time.Time) (float64, bool) {
+	rate, ok := h.rates[weekKey(zip, weekStart)]
+	return rate, ok
+}
+
+// Close closes the persistent history
+func (h *weeklyHistory) Close() error {
+	if h.store == nil {
+		return nil
+	}
+	return h.store.Close()
+}
+
+// weekKey identifies the week of a zip code by the Chicago date it starts on
+func weekKey(zip string, weekStart time.Time) string {
+	return strings.TrimSpace(zip) + "/" + timezone.Date(weekStart)
+}
+
+// historyOnce guards the COVID-19 history, which is shared by all workers
+var historyOnce sync.Once
+
+var (
+	covidHistory    *weeklyHistory
+	covidHistoryErr error
+)
+
+// SetupCovidHistory opens the COVID-19 history at COVID_HISTORY_FILE. Call it when the service
+// starts, so an unreadable file stops the service before it consumes any message.
+func SetupCovidHistory() error {
+	_, err := sharedCovidHistory()
+	return err
+}
+
+// sharedCovidHistory returns the COVID-19 history shared by all workers, opening it on first use
+func sharedCovidHistory() (*weeklyHistory, error) {
+	historyOnce.Do(func() {
+		covidHistory, covidHistoryErr = openWeeklyHistory(os.Getenv("COVID_HISTORY_FILE"))
+	})
+	return covidHistory, covidHistoryErr
+}
+
+// CloseCovidHistory closes the COVID-19 history; call it once no worker transforms records anymore
+func CloseCovidHistory() {
+	if covidHistory == nil {
+		return
+	}
+	if err := covidHistory.Close(); err != nil {
+		log.Printf("Failed to close the COVID-19 history: %v", err)
+	}
+}
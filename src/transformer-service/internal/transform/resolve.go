@@ -0,0 +1,78 @@
+package transform
+
+import (
+	"log"
+	"sync"
+
+	"shared/env"
+)
+
+// Lookups of one batch in flight at once, unless GEOCODE_CONCURRENCY sets another limit
+const defaultGeocodeConcurrency = 8
+
+// point is a latitude and longitude pair
+type point struct {
+	lat, long float64
+}
+
+// zipCodeBatch collects the points of a batch so each distinct point is resolved once
+type zipCodeBatch struct {
+	points  map[point]*string // Zip code of each distinct point, nil until resolved or if it has none
+	lookups int               // Points added, including repeats
+}
+
+func newZipCodeBatch() *zipCodeBatch {
+	return &zipCodeBatch{points: make(map[point]*string)}
+}
+
+// add records a point whose zip code is needed
+func (b *zipCodeBatch) add(lat, long float64) {
+	b.points[point{lat, long}] = nil
+	b.lookups++
+}
+
+// resolve looks up the zip code of every distinct point, with at most GEOCODE_CONCURRENCY lookups in flight.
+// It fails only if the reverse geocoder cannot be set up.
+func (b *zipCodeBatch) resolve() error {
+	if len(b.points) == 0 {
+		return nil
+	}
+	geocoder, err := sharedGeocoder()
+	if err != nil {
+		return err
+	}
+
+	distinct := make([]point, 0, len(b.points))
+	for p := range b.points {
+		distinct = append(distinct, p)
+	}
+
+	jobs := make(chan point)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < min(env.Int("GEOCODE_CONCURRENCY", defaultGeocodeConcurrency), len(distinct)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				zip := zipCode(geocoder, p.lat, p.long)
+				mu.Lock()
+				b.points[p] = zip
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, p := range distinct {
+		jobs <- p
+	}
+	close(jobs)
+	wg.Wait()
+
+	log.Printf("Resolved %d distinct points for %d zip code lookups", len(distinct), b.lookups)
+	return nil
+}
+
+// zipCode returns the resolved zip code of a point added to the batch, or nil if it has none
+func (b *zipCodeBatch) zipCode(lat, long float64) *string {
+	return b.points[point{lat, long}]
+}
@@ -0,0 +1,140 @@
+package transform
+
+import (
+	"log"
+	"sync"
+
+	"shared/env"
+	"shared/model"
+	"shared/timezone"
+)
+
+// COVID-19 severity levels of a zip code and week
+const (
+	severityLow    = "Low"
+	severityMedium = "Medium"
+	severityHigh   = "High"
+)
+
+// severityThresholds are the values from which a zip code and week is rated Medium or High
+type severityThresholds struct {
+	CaseRateMedium   float64 // Weekly cases per 100,000 residents
+	CaseRateHigh     float64
+	PositivityMedium float64 // Share of weekly tests that were positive
+	PositivityHigh   float64
+	TrendRising      float64 // Relative increase of the case rate from the week before that raises the level
+}
+
+// Default thresholds, after the CDC levels of community transmission
+var defaultSeverityThresholds = severityThresholds{
+	CaseRateMedium:   50,
+	CaseRateHigh:     100,
+	PositivityMedium: 0.05,
+	PositivityHigh:   0.10,
+	TrendRising:      0.20,
+}
+
+// Rounding error allowed when comparing a trend with TrendRising, so a rise from 50 to 60,
+// computed as 0.19999999999999996, counts as a rise of 0.20
+const trendTolerance = 1e-9
+
+// severityOnce guards the severity thresholds, which are shared by all workers
+var severityOnce sync.Once
+
+var thresholds severityThresholds
+
+// loadSeverityThresholds reads the thresholds from COVID_CASE_RATE_MEDIUM, COVID_CASE_RATE_HIGH,
+// COVID_POSITIVITY_MEDIUM, COVID_POSITIVITY_HIGH and COVID_TREND_RISING, keeping the defaults of those not set
+func loadSeverityThresholds() severityThresholds {
+	t := defaultSeverityThresholds
+	for key, value := range map[string]*float64{
+		"COVID_CASE_RATE_MEDIUM":  &t.CaseRateMedium,
+		"COVID_CASE_RATE_HIGH":    &t.CaseRateHigh,
+		"COVID_POSITIVITY_MEDIUM": &t.PositivityMedium,
+		"COVID_POSITIVITY_HIGH":   &t.PositivityHigh,
+		"COVID_TREND_RISING":      &t.TrendRising,
+	} {
+		*value = env.Float(key, *value)
+	}
+	if t.CaseRateHigh < t.CaseRateMedium || t.PositivityHigh < t.PositivityMedium {
+		log.Printf("High severity thresholds are below the medium ones, using the defaults")
+		return defaultSeverityThresholds
+	}
+	return t
+}
+
+// classifySeverity sets the severity of each week of a batch, and the trend it was computed from,
+// with the thresholds of the environment and the shared COVID-19 history
+func classifySeverity(cases []model.CovidWeeklyCase) error {
+	severityOnce.Do(func() { thresholds = loadSeverityThresholds() })
+	history, err := sharedCovidHistory()
+	if err != nil {
+		return err
+	}
+	return history.classify(cases, thresholds)
+}
+
+// classify sets the severity of each week of a batch, and the trend it was computed from.
+// The level is the higher of the levels of the weekly case rate and test positivity, raised one
+// level when the case rate rose by TrendRising or more from the week before. The trend is only
+// known when the previous week of the zip code is in the batch or in the history.
+// Weeks without a case rate or positivity have no severity.
+func (h *weeklyHistory) classify(cases []model.CovidWeeklyCase, t severityThresholds) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Record the case rates of the batch first, so weeks find their previous week in any order
+	rates := make(map[string]float64)
+	for _, c := range cases {
+		if c.Case_rate_weekly != nil {
+			rates[weekKey(c.Zip_code, c.Week_start)] = *c.Case_rate_weekly
+		}
+	}
+	if err := h.add(rates); err != nil {
+		return err
+	}
+
+	for i := range cases {
+		c := &cases[i]
+		c.Previous_case_rate_weekly, c.Case_rate_trend = nil, nil
+		if previous, ok := h.rate(c.Zip_code, c.Week_start.In(timezone.Chicago).AddDate(0, 0, -7)); ok {
+			c.Previous_case_rate_weekly = model.Ptr(previous)
+			if c.Case_rate_weekly != nil && previous > 0 {
+				c.Case_rate_trend = model.Ptr(*c.Case_rate_weekly/previous - 1)
+			}
+		}
+		c.Severity = severity(t, c.Case_rate_weekly, c.Percent_tested_positive_weekly, c.Case_rate_trend)
+	}
+	return nil
+}
+
+// severity rates a week from its case rate, test positivity and trend
+func severity(t severityThresholds, caseRate, positivity, trend *float64) *string {
+	if caseRate == nil && positivity == nil {
+		return nil
+	}
+
+	level := 0 // Low, Medium, High
+	if caseRate != nil {
+		level = max(level, levelOf(*caseRate, t.CaseRateMedium, t.CaseRateHigh))
+	}
+	if positivity != nil {
+		level = max(level, levelOf(*positivity, t.PositivityMedium, t.PositivityHigh))
+	}
+	if trend != nil && *trend >= t.TrendRising-trendTolerance {
+		level = min(level+1, 2)
+	}
+	return model.Ptr([]string{severityLow, severityMedium, severityHigh}[level])
+}
+
+// levelOf returns 0, 1 or 2 for a value below medium, from medium and from high
+func levelOf(value, medium, high float64) int {
+	switch {
+	case value >= high:
+		return 2
+	case value >= medium:
+		return 1
+	default:
+		return 0
+	}
+}
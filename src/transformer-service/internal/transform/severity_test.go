@@ -0,0 +1,114 @@
+package transform
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shared/model"
+	"shared/timezone"
+)
+
+func TestSeverityThresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		caseRate   *float64
+		positivity *float64
+		trend      *float64
+		want       string
+	}{
+		{"below every threshold", model.Ptr(49.99), model.Ptr(0.0499), nil, severityLow},
+		{"case rate at medium", model.Ptr(50.0), nil, nil, severityMedium},
+		{"case rate below high", model.Ptr(99.99), nil, nil, severityMedium},
+		{"case rate at high", model.Ptr(100.0), nil, nil, severityHigh},
+		{"positivity at medium", nil, model.Ptr(0.05), nil, severityMedium},
+		{"positivity below high", nil, model.Ptr(0.0999), nil, severityMedium},
+		{"positivity at high", nil, model.Ptr(0.10), nil, severityHigh},
+		{"higher of the two levels", model.Ptr(10.0), model.Ptr(0.12), nil, severityHigh},
+		{"trend below rising", model.Ptr(10.0), nil, model.Ptr(0.1999), severityLow},
+		{"trend at rising", model.Ptr(10.0), nil, model.Ptr(0.20), severityMedium},
+		{"rising raises medium to high", model.Ptr(60.0), nil, model.Ptr(0.5), severityHigh},
+		{"rising keeps high", model.Ptr(150.0), nil, model.Ptr(0.5), severityHigh},
+		{"falling does not lower", model.Ptr(60.0), nil, model.Ptr(-0.5), severityMedium},
+		{"nothing to rate", nil, nil, model.Ptr(0.5), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := severity(defaultSeverityThresholds, tt.caseRate, tt.positivity, tt.trend)
+			if model.Value(got) != tt.want {
+				t.Errorf("severity() = %q, want %q", model.Value(got), tt.want)
+			}
+		})
+	}
+}
+
+// week returns a weekly case of a zip code starting on a Chicago date
+func week(t *testing.T, zip, start string, caseRate float64) model.CovidWeeklyCase {
+	t.Helper()
+	date, err := time.ParseInLocation(time.DateOnly, start, timezone.Chicago)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model.CovidWeeklyCase{Zip_code: zip, Week_start: date.UTC(), Case_rate_weekly: model.Ptr(caseRate)}
+}
+
+func TestClassifyTrend(t *testing.T) {
+	h, err := openWeeklyHistory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The later week first: the trend does not depend on the order within a batch
+	cases := []model.CovidWeeklyCase{
+		week(t, "60601", "2020-11-08", 60), // The week after daylight saving time ends
+		week(t, "60601", "2020-11-01", 50),
+		week(t, "60602", "2020-11-08", 60), // Its previous week is not known
+	}
+	if err := h.classify(cases, defaultSeverityThresholds); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := model.Value(cases[0].Case_rate_trend); got < 0.1999 || got > 0.2001 {
+		t.Errorf("trend of 60601 = %v, want 0.2", got)
+	}
+	if got := model.Value(cases[0].Severity); got != severityHigh {
+		t.Errorf("severity of 60601 = %q, want %q, medium raised by the trend", got, severityHigh)
+	}
+	if cases[1].Previous_case_rate_weekly != nil || cases[2].Case_rate_trend != nil {
+		t.Errorf("weeks without a previous week got a trend")
+	}
+	if got := model.Value(cases[2].Severity); got != severityMedium {
+		t.Errorf("severity of 60602 = %q, want %q", got, severityMedium)
+	}
+}
+
+func TestClassifyTrendAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "covid_history.db")
+	h, err := openWeeklyHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.classify([]model.CovidWeeklyCase{week(t, "60601", "2021-03-07", 80)}, defaultSeverityThresholds); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next week, across the start of daylight saving time, in a batch after a restart
+	h, err = openWeeklyHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	cases := []model.CovidWeeklyCase{week(t, "60601", "2021-03-14", 40)}
+	if err := h.classify(cases, defaultSeverityThresholds); err != nil {
+		t.Fatal(err)
+	}
+	if got := model.Value(cases[0].Previous_case_rate_weekly); got != 80 {
+		t.Errorf("previous case rate = %v, want 80 from before the restart", got)
+	}
+	if got := model.Value(cases[0].Case_rate_trend); got != -0.5 {
+		t.Errorf("trend = %v, want -0.5", got)
+	}
+}
@@ -1,402 +1,307 @@
 package transform
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
-	"strconv"
-	"time"
 
-	"github.com/kelvins/geocoder"
+	"shared/model"
+	"shared/schema"
 )
 
-type TaxiTripsJsonRecords []struct {
-	Trip_id                    string    `json:"trip_id"`
-	Trip_start_timestamp       time.Time `json:"trip_start_timestamp"`
-	Trip_end_timestamp         time.Time `json:"trip_end_timestamp"`
-	Pickup_centroid_latitude   string    `json:"pickup_centroid_latitude"`
-	Pickup_centroid_longitude  string    `json:"pickup_centroid_longitude"`
-	Pickup_community_area      string    `json:"pickup_community_area"`
-	Dropoff_centroid_latitude  string    `json:"dropoff_centroid_latitude"`
-	Dropoff_centroid_longitude string    `json:"dropoff_centroid_longitude"`
-	Dropoff_community_area     string    `json:"dropoff_community_area"`
-	Pickup_zipcode             string    `json:"pickup_zipcode"`
-	Dropoff_zipcode            string    `json:"dropoff_zipcode"`
-}
-
-type BuildingPermitsJsonRecords []struct {
-	Id                     string    `json:"id"`
-	Permit_status          string    `json:"permit_status"`
-	Permit_type            string    `json:"permit_type"`
-	Review_type            string    `json:"review_type"`
-	Application_start_date time.Time `json:"application_start_date"`
-	Issue_date             time.Time `json:"issue_date"`
-	Street_number	       string    `json:"street_number"`
-	Street_direction       string    `json:"street_direction"`
-	Street_name            string    `json:"street_name"`
-	Work_type              string    `json:"work_type"`
-	Total_fee              float64   `json:"total_fee"`
-	Reported_cost          string    `json:"reported_cost"`
-	Community_area         string    `json:"community_area"`
-	Latitude               string    `json:"latitude"`
-	Longitude              string    `json:"longitude"`
-	Zipcode                string    `json:"zipcode"`
-}
-
-type TransportationTripsJsonRecords []struct {
-	Trip_id                    string    `json:"trip_id"`
-	Trip_start_timestamp       time.Time `json:"trip_start_timestamp"`
-	Trip_end_timestamp         time.Time `json:"trip_end_timestamp"`
-	Pickup_census_tract        string    `json:"pickup_census_tract"`
-	Dropoff_census_tract       string    `json:"dropoff_census_tract"`
-	Pickup_community_area      string    `json:"pickup_community_area"`
-	Dropoff_community_area     string    `json:"dropoff_community_area"`
-	Pickup_centroid_latitude   string    `json:"pickup_centroid_latitude"`
-	Pickup_centroid_longitude  string    `json:"pickup_centroid_longitude"`
-	Dropoff_centroid_latitude  string    `json:"dropoff_centroid_latitude"`
-	Dropoff_centroid_longitude string    `json:"dropoff_centroid_longitude"`
-	Pickup_zipcode             string    `json:"pickup_zipcode"`
-	Dropoff_zipcode            string    `json:"dropoff_zipcode"`
-}
-
-// parseTime takes a string and returns time.Time
-func parseTime(value string) (time.Time, error) {
-	formats := []string{
-		time.RFC3339,
-		"2006-01-02T15:04:05.000",
-		"2006-01-02T15:04:05",
-		"2006-01-02T15:04:05.000Z07:00",
-		"2006-01-02T15:04:05Z07:00",
-	}
-
-	var t time.Time
-	var err error
-	for _, format := range formats {
-		t, err = time.Parse(format, value)
-		if err == nil {
-			return t, nil
+func TransformData(message []byte, source string) (interface{}, error) {
+	switch source {
+	case model.TaxiTrips:
+		trips, err := decode[model.TaxiTrip](message)
+		if err != nil {
+			return nil, err
+		}
+		return transformTaxiTrips(trips)
+	case model.CovidCases:
+		cases, err := decode[model.CovidWeeklyCase](message)
+		if err != nil {
+			return nil, err
+		}
+		return transformCovidCases(cases)
+	case model.CovidDailyCases:
+		cases, err := decode[model.CovidDailyCase](message)
+		if err != nil {
+			return nil, err
+		}
+		return transformCovidDailyCases(cases)
+	case model.CovidVulnerabilityIndex:
+		entries, err := decode[model.CCVIEntry](message)
+		if err != nil {
+			return nil, err
+		}
+		return transformCovidVI(entries)
+	case model.BuildingPermits:
+		permits, err := decode[model.BuildingPermit](message)
+		if err != nil {
+			return nil, err
+		}
+		return transformBuildingPermits(permits)
+	case model.CensusData:
+		areas, err := decode[model.CensusArea](message)
+		if err != nil {
+			return nil, err
+		}
+		return transformCensusData(areas)
+	case model.TransportationTrips:
+		trips, err := decode[model.TransportationTrip](message)
+		if err != nil {
+			return nil, err
+		}
+		return transformTransportationTrips(trips)
+	case model.PublicHealthStatistics:
+		stats, err := decode[model.PublicHealthStat](message)
+		if err != nil {
+			return nil, err
+		}
+		return transformPHS(stats)
+	case model.Crimes:
+		crimes, err := decode[model.Crime](message)
+		if err != nil {
+			return nil, err
+		}
+		return transformCrimes(crimes)
+	case model.CTARidership:
+		ridership, err := decode[model.StationRidership](message)
+		if err != nil {
+			return nil, err
+		}
+		return transformCTARidership(ridership)
+	case model.BusinessLicenses:
+		licenses, err := decode[model.BusinessLicense](message)
+		if err != nil {
+			return nil, err
 		}
+		return transformBusinessLicenses(licenses)
+	default:
+		return nil, fmt.Errorf("unknown data source: %s", source)
 	}
-
-	// Return zero value if parsing fails
-	return time.Time{}, fmt.Errorf("invalid time format: %v", value)
 }
 
-func TransformData(message []byte, source string) (interface{}, error) {
-
-	geocoder.ApiKey = os.Getenv("GEOCODER_API_KEY")
-	if geocoder.ApiKey == "" {
-		log.Fatal("GEOCODER_API_KEY is not set")
-	}
-
-	// Unmarshal the message
-	var data []map[string]interface{}
-	err := json.Unmarshal(message, &data)
+// decode converts a message into records, using the schema the message was written with
+func decode[T any](message []byte) ([]T, error) {
+	var records []T
+	_, err := schema.Unmarshal(message, &records)
 	if err != nil {
 		log.Printf("Failed to unmarshal message: %v", err)
 		return nil, fmt.Errorf("failed to unmarshal message: %w", err)
 	}
-
-	// Convert []map[string]interface{} → []interface{}
-	dataAsInterface := make([]interface{}, len(data))
-	for i, v := range data {
-		dataAsInterface[i] = v
-	}
-
-	switch source {
-	case "taxi_trips":
-		return transformTaxiTrips(dataAsInterface)
-	case "covid_cases":
-		return transformCovidCases(data)
-	case "covid_vulnerability_index":
-		return transformCovidVI(data)
-	case "building_permits":
-		return transformBuildingPermits(dataAsInterface)
-	case "census_data":
-		return transformCensusData(data)
-	case "transportation_trips":
-		return transformTransportationTrips(dataAsInterface)
-	case "public_health_statistics":
-		return transformPHS(data)
-	default:
-		return nil, fmt.Errorf("unknown data source: %s", source)
-	}
+	return records, nil
 }
 
-func transformTaxiTrips(data interface{}) (TaxiTripsJsonRecords, error) {
-	var records TaxiTripsJsonRecords
+func transformTaxiTrips(trips []model.TaxiTrip) ([]model.TaxiTrip, error) {
+	var records []model.TaxiTrip
 	var droppedRecords int
 
-	for row := 0; row < len(data.([]interface{})); row++ {
-		record := data.([]interface{})[row].(map[string]interface{})
+	// Resolve each distinct pickup and dropoff point of the batch once
+	zips := newZipCodeBatch()
+	for _, trip := range trips {
+		if trip.Pickup_centroid_latitude != nil && trip.Pickup_centroid_longitude != nil &&
+			trip.Dropoff_centroid_latitude != nil && trip.Dropoff_centroid_longitude != nil {
+			zips.add(*trip.Pickup_centroid_latitude, *trip.Pickup_centroid_longitude)
+			zips.add(*trip.Dropoff_centroid_latitude, *trip.Dropoff_centroid_longitude)
+		}
+	}
+	if err := zips.resolve(); err != nil {
+		return nil, err
+	}
+
+	for _, trip := range trips {
+		// Trips without centroids, or whose centroids the cleaner found outside Chicago, cannot be placed in a zip code
+		if trip.Pickup_centroid_latitude == nil || trip.Pickup_centroid_longitude == nil ||
+			trip.Dropoff_centroid_latitude == nil || trip.Dropoff_centroid_longitude == nil {
+			log.Printf("Missing pickup or dropoff coordinates for trip %s", trip.Trip_id)
+			droppedRecords++
+			continue
+		}
 
 		// Extract pickup latitude and longitude
-		pickupLat, _ := strconv.ParseFloat(record["pickup_centroid_latitude"].(string), 64)
-		pickupLong, _ := strconv.ParseFloat(record["pickup_centroid_longitude"].(string), 64)
-		pickup_location := geocoder.Location{
-			Latitude:  pickupLat,
-			Longitude: pickupLong,
-		}
+		pickupLat := *trip.Pickup_centroid_latitude
+		pickupLong := *trip.Pickup_centroid_longitude
 
 		// Extract dropoff latitude and longitude
-		dropoffLat, _ := strconv.ParseFloat(record["dropoff_centroid_latitude"].(string), 64)
-		dropoffLong, _ := strconv.ParseFloat(record["dropoff_centroid_longitude"].(string), 64)
-		dropoff_location := geocoder.Location{
-			Latitude:  dropoffLat,
-			Longitude: dropoffLong,
-		}
+		dropoffLat := *trip.Dropoff_centroid_latitude
+		dropoffLong := *trip.Dropoff_centroid_longitude
 
-		pickupAddress, _ := geocoder.GeocodingReverse(pickup_location)
-		dropoffAddress, _ := geocoder.GeocodingReverse(dropoff_location)
+		pickupZip := zips.zipCode(pickupLat, pickupLong)
+		dropoffZip := zips.zipCode(dropoffLat, dropoffLong)
 
-		// Handling locations that could not resolve addresses
-		if len(pickupAddress) == 0 {
-			log.Printf("No results found for pickup at latitude : %f and longitude : %f \n", pickupLat, pickupLong)
+		// Handling locations that could not resolve zip codes
+		if pickupZip == nil {
+			log.Printf("No zip code found for pickup at latitude : %f and longitude : %f \n", pickupLat, pickupLong)
 			droppedRecords++
 			continue
 		}
-		if len(dropoffAddress) == 0 {
-			log.Printf("No results found for dropoff at latitude : %f and longitude : %f \n", dropoffLat, dropoffLong)
+		if dropoffZip == nil {
+			log.Printf("No zip code found for dropoff at latitude : %f and longitude : %f \n", dropoffLat, dropoffLong)
 			droppedRecords++
 			continue
 		}
 
-		pickup_zipcode := pickupAddress[0].PostalCode
-		dropoff_zipcode := dropoffAddress[0].PostalCode
-
-		// Parse the timestamps from strings to time.Time
-		tripStartTimestamp, err := parseTime(record["trip_start_timestamp"].(string))
-		if err != nil {
-			return nil, fmt.Errorf("error parsing trip_start_timestamp: %v", err)
-		}
-		tripEndTimestamp, err := parseTime(record["trip_end_timestamp"].(string))
-		if err != nil {
-			return nil, fmt.Errorf("error parsing trip_end_timestamp: %v", err)
-		}
+		trip.Pickup_zipcode = pickupZip
+		trip.Dropoff_zipcode = dropoffZip
 
-		// Create a cleaned trip record
-		trip := struct {
-			Trip_id                    string    `json:"trip_id"`
-			Trip_start_timestamp       time.Time `json:"trip_start_timestamp"`
-			Trip_end_timestamp         time.Time `json:"trip_end_timestamp"`
-			Pickup_centroid_latitude   string    `json:"pickup_centroid_latitude"`
-			Pickup_centroid_longitude  string    `json:"pickup_centroid_longitude"`
-			Pickup_community_area      string    `json:"pickup_community_area"`
-			Dropoff_centroid_latitude  string    `json:"dropoff_centroid_latitude"`
-			Dropoff_centroid_longitude string    `json:"dropoff_centroid_longitude"`
-			Dropoff_community_area     string    `json:"dropoff_community_area"`
-			Pickup_zipcode             string    `json:"pickup_zipcode"`
-			Dropoff_zipcode            string    `json:"dropoff_zipcode"`
-		}{
-			Trip_id:                    record["trip_id"].(string),
-			Trip_start_timestamp:       tripStartTimestamp,
-			Trip_end_timestamp:         tripEndTimestamp,
-			Pickup_centroid_latitude:   record["pickup_centroid_latitude"].(string),
-			Pickup_centroid_longitude:  record["pickup_centroid_longitude"].(string),
-			Pickup_community_area:      record["pickup_community_area"].(string),
-			Dropoff_centroid_latitude:  record["dropoff_centroid_latitude"].(string),
-			Dropoff_centroid_longitude: record["dropoff_centroid_longitude"].(string),
-			Dropoff_community_area:     record["dropoff_community_area"].(string),
-			Pickup_zipcode:             pickup_zipcode,
-			Dropoff_zipcode:            dropoff_zipcode,
-		}
+		// Tag trips from and to O'Hare and Midway
+		trip.Pickup_airport = airport(trip.Pickup_centroid_latitude, trip.Pickup_centroid_longitude, trip.Pickup_community_area)
+		trip.Dropoff_airport = airport(trip.Dropoff_centroid_latitude, trip.Dropoff_centroid_longitude, trip.Dropoff_community_area)
 
 		records = append(records, trip)
 	}
 
+	log.Printf("Number of dropped Taxi Trips records: %d", droppedRecords)
+	logGeocodingStats()
 	return records, nil
 }
 
-func transformCovidCases(data interface{}) (interface{}, error) {
-	// No transformation needed for the COVID cases data
-	return data, nil
+func transformCovidCases(cases []model.CovidWeeklyCase) ([]model.CovidWeeklyCase, error) {
+	// Rate the severity of each zip code and week for the COVID-19 alerts
+	if err := classifySeverity(cases); err != nil {
+		return nil, err
+	}
+	return cases, nil
 }
 
-func transformCovidVI(data interface{}) (interface{}, error) {
+func transformCovidVI(entries []model.CCVIEntry) ([]model.CCVIEntry, error) {
 	// No transformation needed for the COVID vulnerability index data
-	return data, nil
+	return entries, nil
 }
 
-func transformBuildingPermits(data interface{}) (BuildingPermitsJsonRecords, error) {
-	var records BuildingPermitsJsonRecords
+func transformBuildingPermits(permits []model.BuildingPermit) ([]model.BuildingPermit, error) {
+	var records []model.BuildingPermit
 	var droppedRecords int
 
-	for row := 0; row < len(data.([]interface{})); row++ {
-		record := data.([]interface{})[row].(map[string]interface{})
-		zipcode := ""
-
-		// Extract latitude and longitude
-		lat, _ := strconv.ParseFloat(record["latitude"].(string), 64)
-		long, _ := strconv.ParseFloat(record["longitude"].(string), 64)
-		location := geocoder.Location{
-			Latitude:  lat,
-			Longitude: long,
+	// Resolve each distinct permit location of the batch once
+	zips := newZipCodeBatch()
+	for _, permit := range permits {
+		if permit.Latitude != nil && permit.Longitude != nil {
+			zips.add(*permit.Latitude, *permit.Longitude)
 		}
+	}
+	if err := zips.resolve(); err != nil {
+		return nil, err
+	}
 
-		if lat != 0.000000 && long != 0.000000 {
-			address, _ := geocoder.GeocodingReverse(location)
+	for _, permit := range permits {
+		// Resolve the zip code of permits with coordinates inside Chicago, the cleaner nulls the others
+		if permit.Latitude != nil && permit.Longitude != nil {
+			lat, long := *permit.Latitude, *permit.Longitude
+			zip := zips.zipCode(lat, long)
 
-			// Handling locations that could not resolve addresses
-			if len(address) == 0 && record["community_area"] == "" {
-				log.Printf("No results found for latitude : %f and longitude : %f \n", lat, long)
+			// Handling locations that could not resolve zip codes
+			if zip == nil && permit.Community_area == nil {
+				log.Printf("No zip code found for latitude : %f and longitude : %f \n", lat, long)
 				droppedRecords++
 				continue
 			}
-	
-			zipcode = address[0].PostalCode
-		} else if record["community_area"] == "" {
+
+			permit.Zipcode = zip
+		} else if permit.Community_area == nil {
 			log.Print("No zipcode or community area")
 			droppedRecords++
 			continue
 		}
 
-		applicationStartDate, err := parseTime(record["application_start_date"].(string))
-		if err != nil {
-			return nil, fmt.Errorf("error parsing application_start_date: %v", err)
-		}
-		issueDate, err := parseTime(record["issue_date"].(string))
-		if err != nil {
-			return nil, fmt.Errorf("error parsing issue_date: %v", err)
-		}
-
-		// Create a cleaned building permit record
-		permit := struct {
-			Id                     string    `json:"id"`
-			Permit_status          string    `json:"permit_status"`
-			Permit_type            string    `json:"permit_type"`
-			Review_type            string    `json:"review_type"`
-			Application_start_date time.Time `json:"application_start_date"`
-			Issue_date             time.Time `json:"issue_date"`
-			Street_number	       string    `json:"street_number"`
-			Street_direction       string    `json:"street_direction"`
-			Street_name            string    `json:"street_name"`
-			Work_type              string    `json:"work_type"`
-			Total_fee              float64   `json:"total_fee"`
-			Reported_cost          string    `json:"reported_cost"`
-			Community_area         string    `json:"community_area"`
-			Latitude               string    `json:"latitude"`
-			Longitude              string    `json:"longitude"`
-			Zipcode                string    `json:"zipcode"`
-		}{
-			Id:                     record["id"].(string),
-			Permit_status:          record["permit_status"].(string),
-			Permit_type:            record["permit_type"].(string),
-			Review_type:            record["review_type"].(string),
-			Application_start_date: applicationStartDate,
-			Issue_date:             issueDate,
-			Street_number:          record["street_number"].(string),
-			Street_direction:		record["street_direction"].(string),
-			Street_name:			record["street_name"].(string),
-			Work_type:              record["work_type"].(string),
-			Total_fee:              record["total_fee"].(float64),
-			Reported_cost:          record["reported_cost"].(string),
-			Community_area:         record["community_area"].(string),
-			Latitude:               record["latitude"].(string),
-			Longitude:              record["longitude"].(string),
-			Zipcode:                zipcode,
-		}
-
 		records = append(records, permit)
 	}
 
+	log.Printf("Number of dropped Building Permits records: %d", droppedRecords)
+	logGeocodingStats()
 	return records, nil
 }
 
-func transformCensusData(data interface{}) (interface{}, error) {
+func transformCensusData(areas []model.CensusArea) ([]model.CensusArea, error) {
 	// No transformation needed for the census data
-	return data, nil
+	return areas, nil
 }
 
-func transformTransportationTrips(data interface{}) (TransportationTripsJsonRecords, error) {
-	var records TransportationTripsJsonRecords
+func transformTransportationTrips(trips []model.TransportationTrip) ([]model.TransportationTrip, error) {
+	var records []model.TransportationTrip
 	var droppedRecords int
 
-	for row := 0; row < len(data.([]interface{})); row++ {
-		record := data.([]interface{})[row].(map[string]interface{})
+	// Resolve each distinct pickup and dropoff point of the batch once
+	zips := newZipCodeBatch()
+	for _, trip := range trips {
+		if trip.Pickup_centroid_latitude != nil && trip.Pickup_centroid_longitude != nil &&
+			trip.Dropoff_centroid_latitude != nil && trip.Dropoff_centroid_longitude != nil {
+			zips.add(*trip.Pickup_centroid_latitude, *trip.Pickup_centroid_longitude)
+			zips.add(*trip.Dropoff_centroid_latitude, *trip.Dropoff_centroid_longitude)
+		}
+	}
+	if err := zips.resolve(); err != nil {
+		return nil, err
+	}
 
-		// Extract pickup latitude and longitude
-		pickupLat, _ := strconv.ParseFloat(record["pickup_centroid_latitude"].(string), 64)
-		pickupLong, _ := strconv.ParseFloat(record["pickup_centroid_longitude"].(string), 64)
-		pickup_location := geocoder.Location{
-			Latitude:  pickupLat,
-			Longitude: pickupLong,
+	for _, trip := range trips {
+		// Trips without centroids, or whose centroids the cleaner found outside Chicago, cannot be placed in a zip code
+		if trip.Pickup_centroid_latitude == nil || trip.Pickup_centroid_longitude == nil ||
+			trip.Dropoff_centroid_latitude == nil || trip.Dropoff_centroid_longitude == nil {
+			log.Printf("Missing pickup or dropoff coordinates for trip %s", trip.Trip_id)
+			droppedRecords++
+			continue
 		}
 
+		// Extract pickup latitude and longitude
+		pickupLat := *trip.Pickup_centroid_latitude
+		pickupLong := *trip.Pickup_centroid_longitude
+
 		// Extract dropoff latitude and longitude
-		dropoffLat, _ := strconv.ParseFloat(record["dropoff_centroid_latitude"].(string), 64)
-		dropoffLong, _ := strconv.ParseFloat(record["dropoff_centroid_longitude"].(string), 64)
-		dropoff_location := geocoder.Location{
-			Latitude:  dropoffLat,
-			Longitude: dropoffLong,
-		}
+		dropoffLat := *trip.Dropoff_centroid_latitude
+		dropoffLong := *trip.Dropoff_centroid_longitude
 
-		pickupAddress, _ := geocoder.GeocodingReverse(pickup_location)
-		dropoffAddress, _ := geocoder.GeocodingReverse(dropoff_location)
+		pickupZip := zips.zipCode(pickupLat, pickupLong)
+		dropoffZip := zips.zipCode(dropoffLat, dropoffLong)
 
-		// Handling locations that could not resolve addresses
-		if len(pickupAddress) == 0 {
-			log.Printf("No results found for pickup at latitude : %f and longitude : %f \n", pickupLat, pickupLong)
+		// Handling locations that could not resolve zip codes
+		if pickupZip == nil {
+			log.Printf("No zip code found for pickup at latitude : %f and longitude : %f \n", pickupLat, pickupLong)
 			droppedRecords++
 			continue
 		}
-		if len(dropoffAddress) == 0 {
-			log.Printf("No results found for dropoff at latitude : %f and longitude : %f \n", dropoffLat, dropoffLong)
+		if dropoffZip == nil {
+			log.Printf("No zip code found for dropoff at latitude : %f and longitude : %f \n", dropoffLat, dropoffLong)
 			droppedRecords++
 			continue
 		}
 
-		pickup_zipcode := pickupAddress[0].PostalCode
-		dropoff_zipcode := dropoffAddress[0].PostalCode
-
-		// Parse the timestamps from strings to time.Time
-		tripStartTimestamp, err := parseTime(record["trip_start_timestamp"].(string))
-		if err != nil {
-			return nil, fmt.Errorf("error parsing trip_start_timestamp: %v", err)
-		}
-		tripEndTimestamp, err := parseTime(record["trip_end_timestamp"].(string))
-		if err != nil {
-			return nil, fmt.Errorf("error parsing trip_end_timestamp: %v", err)
-		}
+		trip.Pickup_zipcode = pickupZip
+		trip.Dropoff_zipcode = dropoffZip
 
-		// Create a cleaned transportation trip record
-		trip := struct {
-			Trip_id                    string    `json:"trip_id"`
-			Trip_start_timestamp       time.Time `json:"trip_start_timestamp"`
-			Trip_end_timestamp         time.Time `json:"trip_end_timestamp"`
-			Pickup_census_tract        string    `json:"pickup_census_tract"`
-			Dropoff_census_tract       string    `json:"dropoff_census_tract"`
-			Pickup_community_area      string    `json:"pickup_community_area"`
-			Dropoff_community_area     string    `json:"dropoff_community_area"`
-			Pickup_centroid_latitude   string    `json:"pickup_centroid_latitude"`
-			Pickup_centroid_longitude  string    `json:"pickup_centroid_longitude"`
-			Dropoff_centroid_latitude  string    `json:"dropoff_centroid_latitude"`
-			Dropoff_centroid_longitude string    `json:"dropoff_centroid_longitude"`
-			Pickup_zipcode             string    `json:"pickup_zipcode"`
-			Dropoff_zipcode            string    `json:"dropoff_zipcode"`
-		}{
-			Trip_id:                    record["trip_id"].(string),
-			Trip_start_timestamp:       tripStartTimestamp,
-			Trip_end_timestamp:         tripEndTimestamp,
-			Pickup_census_tract:        record["pickup_census_tract"].(string),
-			Dropoff_census_tract:       record["dropoff_census_tract"].(string),
-			Pickup_community_area:      record["pickup_community_area"].(string),
-			Dropoff_community_area:     record["dropoff_community_area"].(string),
-			Pickup_centroid_latitude:   record["pickup_centroid_latitude"].(string),
-			Pickup_centroid_longitude:  record["pickup_centroid_longitude"].(string),
-			Dropoff_centroid_latitude:  record["dropoff_centroid_latitude"].(string),
-			Dropoff_centroid_longitude: record["dropoff_centroid_longitude"].(string),
-			Pickup_zipcode:             pickup_zipcode,
-			Dropoff_zipcode:            dropoff_zipcode,
-		}
+		// Tag trips from and to O'Hare and Midway
+		trip.Pickup_airport = airport(trip.Pickup_centroid_latitude, trip.Pickup_centroid_longitude, trip.Pickup_community_area)
+		trip.Dropoff_airport = airport(trip.Dropoff_centroid_latitude, trip.Dropoff_centroid_longitude, trip.Dropoff_community_area)
 
 		records = append(records, trip)
 	}
 
+	log.Printf("Number of dropped Transportation Trips records: %d", droppedRecords)
+	logGeocodingStats()
 	return records, nil
 }
 
-func transformPHS(data interface{}) (interface{}, error) {
+func transformPHS(stats []model.PublicHealthStat) ([]model.PublicHealthStat, error) {
 	// No transformation needed for the public health statistics data
-	return data, nil
+	return stats, nil
+}
+
+func transformCovidDailyCases(cases []model.CovidDailyCase) ([]model.CovidDailyCase, error) {
+	// No transformation needed for the citywide daily COVID data
+	return cases, nil
+}
+
+func transformCrimes(crimes []model.Crime) ([]model.Crime, error) {
+	// No transformation needed for the crimes data
+	return crimes, nil
+}
+
+func transformCTARidership(ridership []model.StationRidership) ([]model.StationRidership, error) {
+	// No transformation needed for the CTA ridership data
+	return ridership, nil
+}
+
+func transformBusinessLicenses(licenses []model.BusinessLicense) ([]model.BusinessLicense, error) {
+	// No transformation needed for the business licenses data
+	return licenses, nil
 }